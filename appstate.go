@@ -405,3 +405,18 @@ func (cli *Client) SendAppState(patch appstate.PatchInfo) error {
 
 	return cli.FetchAppState(patch.Type, false, false)
 }
+
+// ClearChat clears all messages in the given chat on all linked devices, including starred messages.
+//
+// To keep starred messages or to specify the last message in the chat, use
+// SendAppState with appstate.BuildClearChat directly.
+func (cli *Client) ClearChat(jid types.JID) error {
+	return cli.SendAppState(appstate.BuildClearChat(jid, time.Time{}, nil, true, false))
+}
+
+// DeleteChat deletes the given chat on all linked devices.
+//
+// To specify the last message in the chat, use SendAppState with appstate.BuildDeleteChat directly.
+func (cli *Client) DeleteChat(jid types.JID) error {
+	return cli.SendAppState(appstate.BuildDeleteChat(jid, time.Time{}, nil, false))
+}
@@ -58,6 +58,30 @@ func BuildMute(target types.JID, mute bool, muteDuration time.Duration) PatchInf
 	}
 }
 
+func newMessageRange(lastMessageTimestamp time.Time, lastMessageKey *waProto.MessageKey) *waProto.SyncActionMessageRange {
+	if lastMessageTimestamp.IsZero() {
+		lastMessageTimestamp = time.Now()
+	}
+	messageRange := &waProto.SyncActionMessageRange{
+		LastMessageTimestamp: proto.Int64(lastMessageTimestamp.Unix()),
+		// TODO set LastSystemMessageTimestamp?
+	}
+	if lastMessageKey != nil {
+		messageRange.Messages = []*waProto.SyncActionMessage{{
+			Key:       lastMessageKey,
+			Timestamp: proto.Int64(lastMessageTimestamp.Unix()),
+		}}
+	}
+	return messageRange
+}
+
+func boolToIndexString(val bool) string {
+	if val {
+		return "1"
+	}
+	return "0"
+}
+
 func newPinMutationInfo(target types.JID, pin bool) MutationInfo {
 	return MutationInfo{
 		Index:   []string{IndexPin, target.String()},
@@ -86,30 +110,17 @@ func BuildPin(target types.JID, pin bool) PatchInfo {
 //
 // Archiving a chat will also unpin it automatically.
 func BuildArchive(target types.JID, archive bool, lastMessageTimestamp time.Time, lastMessageKey *waProto.MessageKey) PatchInfo {
-	if lastMessageTimestamp.IsZero() {
-		lastMessageTimestamp = time.Now()
-	}
 	archiveMutationInfo := MutationInfo{
 		Index:   []string{IndexArchive, target.String()},
 		Version: 3,
 		Value: &waProto.SyncActionValue{
 			ArchiveChatAction: &waProto.ArchiveChatAction{
-				Archived: &archive,
-				MessageRange: &waProto.SyncActionMessageRange{
-					LastMessageTimestamp: proto.Int64(lastMessageTimestamp.Unix()),
-					// TODO set LastSystemMessageTimestamp?
-				},
+				Archived:     &archive,
+				MessageRange: newMessageRange(lastMessageTimestamp, lastMessageKey),
 			},
 		},
 	}
 
-	if lastMessageKey != nil {
-		archiveMutationInfo.Value.ArchiveChatAction.MessageRange.Messages = []*waProto.SyncActionMessage{{
-			Key:       lastMessageKey,
-			Timestamp: proto.Int64(lastMessageTimestamp.Unix()),
-		}}
-	}
-
 	mutations := []MutationInfo{archiveMutationInfo}
 	if archive {
 		mutations = append(mutations, newPinMutationInfo(target, false))
@@ -123,6 +134,45 @@ func BuildArchive(target types.JID, archive bool, lastMessageTimestamp time.Time
 	return result
 }
 
+// BuildClearChat builds an app state patch for clearing all messages in a chat.
+//
+// The last message timestamp and last message key are optional and can be set to zero values (`time.Time{}` and `nil`).
+// If deleteStarred is false, starred messages will be kept in the chat. If deleteMedia is true,
+// downloaded media files of the messages should be deleted too.
+func BuildClearChat(target types.JID, lastMessageTimestamp time.Time, lastMessageKey *waProto.MessageKey, deleteStarred, deleteMedia bool) PatchInfo {
+	return PatchInfo{
+		Type: WAPatchRegularHigh,
+		Mutations: []MutationInfo{{
+			Index:   []string{IndexClearChat, target.String(), boolToIndexString(deleteStarred), boolToIndexString(deleteMedia)},
+			Version: 6,
+			Value: &waProto.SyncActionValue{
+				ClearChatAction: &waProto.ClearChatAction{
+					MessageRange: newMessageRange(lastMessageTimestamp, lastMessageKey),
+				},
+			},
+		}},
+	}
+}
+
+// BuildDeleteChat builds an app state patch for deleting a chat.
+//
+// The last message timestamp and last message key are optional and can be set to zero values (`time.Time{}` and `nil`).
+// If deleteMedia is true, downloaded media files of the messages should be deleted too.
+func BuildDeleteChat(target types.JID, lastMessageTimestamp time.Time, lastMessageKey *waProto.MessageKey, deleteMedia bool) PatchInfo {
+	return PatchInfo{
+		Type: WAPatchRegularHigh,
+		Mutations: []MutationInfo{{
+			Index:   []string{IndexDeleteChat, target.String(), boolToIndexString(deleteMedia)},
+			Version: 6,
+			Value: &waProto.SyncActionValue{
+				DeleteChatAction: &waProto.DeleteChatAction{
+					MessageRange: newMessageRange(lastMessageTimestamp, lastMessageKey),
+				},
+			},
+		}},
+	}
+}
+
 func newLabelChatMutation(target types.JID, labelID string, labeled bool) MutationInfo {
 	return MutationInfo{
 		Index:   []string{IndexLabelAssociationChat, labelID, target.String()},
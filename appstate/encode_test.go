@@ -0,0 +1,64 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package appstate
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestBuildClearDeleteChat(t *testing.T) {
+	target := types.NewJID("1234567890", types.DefaultUserServer)
+	ts := time.Unix(1700000000, 0)
+	testCases := []struct {
+		name      string
+		patch     PatchInfo
+		wantIndex string
+		wantClear bool
+	}{
+		{"ClearChatKeepStarred", BuildClearChat(target, ts, nil, false, false), `["clearChat","1234567890@s.whatsapp.net","0","0"]`, true},
+		{"ClearChatDeleteStarred", BuildClearChat(target, ts, nil, true, false), `["clearChat","1234567890@s.whatsapp.net","1","0"]`, true},
+		{"ClearChatDeleteMedia", BuildClearChat(target, ts, nil, false, true), `["clearChat","1234567890@s.whatsapp.net","0","1"]`, true},
+		{"DeleteChat", BuildDeleteChat(target, ts, nil, false), `["deleteChat","1234567890@s.whatsapp.net","0"]`, false},
+		{"DeleteChatDeleteMedia", BuildDeleteChat(target, ts, nil, true), `["deleteChat","1234567890@s.whatsapp.net","1"]`, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.patch.Type != WAPatchRegularHigh {
+				t.Errorf("unexpected patch type %q", tc.patch.Type)
+			}
+			if len(tc.patch.Mutations) != 1 {
+				t.Fatalf("expected 1 mutation, got %d", len(tc.patch.Mutations))
+			}
+			mutation := tc.patch.Mutations[0]
+			if mutation.Version != 6 {
+				t.Errorf("expected version 6, got %d", mutation.Version)
+			}
+			// The index MAC is computed over exactly these bytes in EncodePatch.
+			indexBytes, err := json.Marshal(mutation.Index)
+			if err != nil {
+				t.Fatalf("failed to marshal index: %v", err)
+			}
+			if string(indexBytes) != tc.wantIndex {
+				t.Errorf("expected index %s, got %s", tc.wantIndex, indexBytes)
+			}
+			var messageRange *waProto.SyncActionMessageRange
+			if tc.wantClear {
+				messageRange = mutation.Value.GetClearChatAction().GetMessageRange()
+			} else {
+				messageRange = mutation.Value.GetDeleteChatAction().GetMessageRange()
+			}
+			if messageRange.GetLastMessageTimestamp() != ts.Unix() {
+				t.Errorf("expected last message timestamp %d, got %d", ts.Unix(), messageRange.GetLastMessageTimestamp())
+			}
+		})
+	}
+}
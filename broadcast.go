@@ -7,13 +7,27 @@
 package whatsmeow
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
 	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/proto/waE2E"
 	"go.mau.fi/whatsmeow/types"
 )
 
+// SendStatus posts the given message as a status update (story) visible to your contacts.
+//
+// This is a shortcut for SendMessage to types.StatusBroadcastJID. The recipients are chosen based on the
+// status privacy settings (see GetStatusPrivacy) and the contact list in the store, and the message is
+// encrypted with a sender key like group messages.
+//
+// Incoming status updates are dispatched as normal *events.Message events with Info.Chat set to
+// types.StatusBroadcastJID.
+func (cli *Client) SendStatus(ctx context.Context, message *waE2E.Message, extra ...SendRequestExtra) (SendResponse, error) {
+	return cli.SendMessage(ctx, types.StatusBroadcastJID, message, extra...)
+}
+
 func (cli *Client) getBroadcastListParticipants(jid types.JID) ([]types.JID, error) {
 	var list []types.JID
 	var err error
@@ -40,6 +40,7 @@ import (
 type EventHandler func(evt interface{})
 type nodeHandler func(node *waBinary.Node)
 
+// nextHandlerID is only used to generate unique event handler IDs, so sharing it between clients is safe.
 var nextHandlerID uint32
 
 type wrappedEventHandler struct {
@@ -52,6 +53,11 @@ type deviceCache struct {
 	dhash   string
 }
 
+type groupMembersCache struct {
+	members              []types.JID
+	participantVersionID string
+}
+
 // Client contains everything necessary to connect to and interact with the WhatsApp web API.
 type Client struct {
 	Store   *store.Device
@@ -66,6 +72,7 @@ type Client struct {
 
 	isLoggedIn            atomic.Bool
 	expectedDisconnect    atomic.Bool
+	lastKeepAliveLatency  atomic.Int64
 	EnableAutoReconnect   bool
 	LastSuccessfulConnect time.Time
 	AutoReconnectErrors   int
@@ -85,6 +92,12 @@ type Client struct {
 	pendingPhoneRerequests             map[types.MessageID]context.CancelFunc
 	pendingPhoneRerequestsLock         sync.RWMutex
 
+	pendingReadReceipts     map[readReceiptKey]*pendingReadReceipt
+	pendingReadReceiptsLock sync.Mutex
+
+	chatPresenceRefresh     map[types.JID]*chatPresenceRefresh
+	chatPresenceRefreshLock sync.Mutex
+
 	appStateProc     *appstate.Processor
 	appStateSyncLock sync.Mutex
 
@@ -114,20 +127,29 @@ type Client struct {
 	appStateKeyRequests     map[string]time.Time
 	appStateKeyRequestsLock sync.RWMutex
 
-	messageSendLock sync.Mutex
+	messageSendLock   sync.Mutex
+	chatSendLocks     map[types.JID]*chatSendLock
+	chatSendLocksLock sync.Mutex
 
 	privacySettingsCache atomic.Value
 
-	groupParticipantsCache     map[types.JID][]types.JID
+	groupParticipantsCache     map[types.JID]groupMembersCache
 	groupParticipantsCacheLock sync.Mutex
+	leftGroups                 map[types.JID]struct{}
+	leftGroupsLock             sync.RWMutex
 	userDevicesCache           map[types.JID]deviceCache
 	userDevicesCacheLock       sync.Mutex
 
 	recentMessagesMap  map[recentMessageKey]RecentMessage
-	recentMessagesList [recentMessagesSize]recentMessageKey
+	recentMessagesList []recentMessageKey
 	recentMessagesPtr  int
 	recentMessagesLock sync.RWMutex
 
+	seenMessagesMap  map[seenMessageKey]struct{}
+	seenMessagesList []seenMessageKey
+	seenMessagesPtr  int
+	seenMessagesLock sync.Mutex
+
 	sessionRecreateHistory     map[types.JID]time.Time
 	sessionRecreateHistoryLock sync.Mutex
 	// GetMessageForRetry is used to find the source message for handling retry receipts
@@ -136,23 +158,88 @@ type Client struct {
 	// PreRetryCallback is called before a retry receipt is accepted.
 	// If it returns false, the accepting will be cancelled and the retry receipt will be ignored.
 	PreRetryCallback func(receipt *events.Receipt, id types.MessageID, retryCount int, msg *waProto.Message) bool
+	// RetryReceiptHook is called whenever the retry count of an incoming message that failed to decrypt is incremented,
+	// including when the count exceeds MaxRetryReceipts and no receipt is sent. This is meant for metrics.
+	RetryReceiptHook func(info *types.MessageInfo, retryCount int)
 
 	// PrePairCallback is called before pairing is completed. If it returns false, the pairing will be cancelled and
 	// the client will disconnect.
 	PrePairCallback func(jid types.JID, platform, businessName string) bool
 
+	// HistorySyncDownloadProgressHook is called periodically while history sync blobs are being downloaded.
+	HistorySyncDownloadProgressHook DownloadProgressFunc
+
 	// GetClientPayload is called to get the client payload for connecting to the server.
 	// This should NOT be used for WhatsApp (to change the OS name, update fields in store.BaseClientPayload directly).
 	GetClientPayload func() *waProto.ClientPayload
 
+	// The maximum number of retry receipts to send for an incoming message that fails to decrypt.
+	// Defaults to DefaultMaxRetryReceipts, which is also used if the value is zero or negative.
+	// The first retry receipt is sent immediately, after that the delay before each receipt doubles starting from 1 second,
+	// up to 1 minute.
+	MaxRetryReceipts int
+
+	// The number of sent messages to keep in memory for responding to retry receipts. Defaults to DefaultRecentMessagesSize.
+	// Messages that fall out of the cache are requested from GetMessageForRetry instead.
+	RecentMessagesSize int
+	// The maximum age of messages in the recent message cache. Zero means messages are only evicted by count.
+	RecentMessagesMaxAge time.Duration
+
+	// The number of recently handled incoming message IDs to remember for skipping duplicate deliveries.
+	// Duplicates are still acked and receipted, but don't dispatch events. Defaults to DefaultMessageDedupSize, zero disables deduplication.
+	MessageDedupSize int
+
+	// If non-zero, MarkRead calls for the same chat within this window are combined into a single receipt.
+	// The receipt is sent when the window started by the first call ends.
+	MarkReadDebounce time.Duration
+
+	// If non-zero, SendChatPresence with types.ChatPresenceComposing keeps resending the composing state at this interval
+	// until another state is sent to the same chat or the client disconnects. WhatsApp clients hide the typing indicator
+	// after a few seconds, so the interval should be a bit shorter than that (e.g. 5 seconds).
+	ChatPresenceRefreshInterval time.Duration
+
+	// Should delivery receipts be sent automatically for incoming messages? Defaults to true.
+	// If false, messages are still acked to the server, but the sender won't see them as delivered
+	// unless SendDeliveryReceipt or MarkRead is called.
+	AutomaticReceipts bool
+
 	// Should untrusted identity errors be handled automatically? If true, the stored identity and existing signal
 	// sessions will be removed on untrusted identity errors, and an events.IdentityChange will be dispatched.
 	// If false, decrypting a message from untrusted devices will fail.
 	AutoTrustIdentity bool
 
+	// Should the padding of decrypted messages be validated? If true (the default), messages with invalid padding
+	// fail to decrypt. If false, the padding length is read from the last byte without checking the other padding bytes.
+	CheckPadding bool
+
+	// Should message events include the raw decrypted protobuf bytes in addition to the parsed message?
+	// This is disabled by default to avoid keeping two copies of every message in memory.
+	IncludeRawMessageBytes bool
+	// Should message events include the XML node that the message was parsed from?
+	// This is useful for debugging and for reading attributes that aren't parsed into MessageInfo.
+	IncludeSourceNode bool
+
 	// Should SubscribePresence return an error if no privacy token is stored for the user?
 	ErrorOnSubscribePresenceWithoutToken bool
 
+	// The number of times to try downloading media from each host when the download fails with a network error
+	// or a retryable HTTP status (e.g. 5xx or 429). Expired media (404/410) is never retried. Defaults to 5.
+	MediaDownloadAttempts int
+
+	// The maximum size of attachments that are downloaded automatically (see AutoDownloadMedia).
+	// Larger attachments must be downloaded manually. Defaults to 16 MiB.
+	AutoDownloadMaxSize int
+	// The maximum time to spend on an automatic download, including retries. Automatic downloads block the
+	// event handler queue, so this should be kept short. If the download doesn't finish in time, the message
+	// event is dispatched without the attachment. Defaults to 30 seconds, which is also used if the value is zero or negative.
+	AutoDownloadTimeout time.Duration
+
+	autoDownloadTypes atomic.Pointer[map[MediaType]struct{}]
+
+	// Should panics in event handlers be recovered? If true (the default), panics are logged and the remaining
+	// event handlers are still called. If false, a panicking handler will crash the goroutine that dispatched the event.
+	RecoverEventHandlerPanics bool
+
 	phoneLinkingCache *phoneLinkingCache
 
 	uniqueID  string
@@ -204,9 +291,7 @@ func NewClient(deviceStore *store.Device, log waLog.Logger) *Client {
 	}
 	uniqueIDPrefix := random.Bytes(2)
 	cli := &Client{
-		http: &http.Client{
-			Transport: (http.DefaultTransport.(*http.Transport)).Clone(),
-		},
+		http:            newDefaultMediaHTTPClient(),
 		proxy:           http.ProxyFromEnvironment,
 		Store:           deviceStore,
 		Log:             log,
@@ -216,6 +301,7 @@ func NewClient(deviceStore *store.Device, log waLog.Logger) *Client {
 		responseWaiters: make(map[string]chan<- *waBinary.Node),
 		eventHandlers:   make([]wrappedEventHandler, 0, 1),
 		messageRetries:  make(map[string]int),
+		chatSendLocks:   make(map[types.JID]*chatSendLock),
 		handlerQueue:    make(chan *waBinary.Node, handlerQueueSize),
 		appStateProc:    appstate.NewProcessor(deviceStore, log.Sub("AppState")),
 		socketWait:      make(chan struct{}),
@@ -224,18 +310,30 @@ func NewClient(deviceStore *store.Device, log waLog.Logger) *Client {
 
 		historySyncNotifications: make(chan *waProto.HistorySyncNotification, 32),
 
-		groupParticipantsCache: make(map[types.JID][]types.JID),
+		groupParticipantsCache: make(map[types.JID]groupMembersCache),
+		leftGroups:             make(map[types.JID]struct{}),
 		userDevicesCache:       make(map[types.JID]deviceCache),
 
-		recentMessagesMap:      make(map[recentMessageKey]RecentMessage, recentMessagesSize),
+		recentMessagesMap:      make(map[recentMessageKey]RecentMessage, DefaultRecentMessagesSize),
 		sessionRecreateHistory: make(map[types.JID]time.Time),
 		GetMessageForRetry:     func(requester, to types.JID, id types.MessageID) *waProto.Message { return nil },
 		appStateKeyRequests:    make(map[string]time.Time),
 
 		pendingPhoneRerequests: make(map[types.MessageID]context.CancelFunc),
-
-		EnableAutoReconnect: true,
-		AutoTrustIdentity:   true,
+		pendingReadReceipts:    make(map[readReceiptKey]*pendingReadReceipt),
+		chatPresenceRefresh:    make(map[types.JID]*chatPresenceRefresh),
+
+		EnableAutoReconnect:       true,
+		AutoTrustIdentity:         true,
+		AutomaticReceipts:         true,
+		RecoverEventHandlerPanics: true,
+		CheckPadding:              true,
+		MaxRetryReceipts:          DefaultMaxRetryReceipts,
+		RecentMessagesSize:        DefaultRecentMessagesSize,
+		MessageDedupSize:          DefaultMessageDedupSize,
+		AutoDownloadMaxSize:       16 * 1024 * 1024,
+		AutoDownloadTimeout:       defaultAutoDownloadTimeout,
+		MediaDownloadAttempts:     5,
 	}
 	cli.nodeHandlers = map[string]nodeHandler{
 		"message":      cli.handleEncryptedMessage,
@@ -313,7 +411,11 @@ func (cli *Client) SetProxy(proxy Proxy, opts ...SetProxyOptions) {
 		cli.socksProxy = nil
 	}
 	if !opt.NoMedia {
-		transport := cli.http.Transport.(*http.Transport)
+		transport, ok := cli.cloneMediaTransport()
+		if !ok {
+			cli.Log.Warnf("Not setting proxy for media: custom media HTTP client doesn't use *http.Transport")
+			return
+		}
 		transport.Proxy = proxy
 		transport.Dial = nil
 		transport.DialContext = nil
@@ -340,10 +442,14 @@ func (cli *Client) SetSOCKSProxy(px proxy.Dialer, opts ...SetProxyOptions) {
 		cli.proxy = nil
 	}
 	if !opt.NoMedia {
-		transport := cli.http.Transport.(*http.Transport)
+		transport, ok := cli.cloneMediaTransport()
+		if !ok {
+			cli.Log.Warnf("Not setting proxy for media: custom media HTTP client doesn't use *http.Transport")
+			return
+		}
 		transport.Proxy = nil
-		transport.Dial = cli.socksProxy.Dial
-		contextDialer, ok := cli.socksProxy.(proxy.ContextDialer)
+		transport.Dial = px.Dial
+		contextDialer, ok := px.(proxy.ContextDialer)
 		if ok {
 			transport.DialContext = contextDialer.DialContext
 		} else {
@@ -352,6 +458,44 @@ func (cli *Client) SetSOCKSProxy(px proxy.Dialer, opts ...SetProxyOptions) {
 	}
 }
 
+// cloneMediaTransport replaces the media HTTP client with a copy that has its own clone of the transport,
+// so that changing the transport doesn't affect a client or transport passed to SetMediaHTTPClient.
+func (cli *Client) cloneMediaTransport() (*http.Transport, bool) {
+	var transport *http.Transport
+	if cli.http.Transport == nil {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	} else if existing, ok := cli.http.Transport.(*http.Transport); ok {
+		transport = existing.Clone()
+	} else {
+		return nil, false
+	}
+	newClient := *cli.http
+	newClient.Transport = transport
+	cli.http = &newClient
+	return transport, true
+}
+
+// newDefaultMediaHTTPClient returns the HTTP client used for media uploads and downloads by default.
+func newDefaultMediaHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: (http.DefaultTransport.(*http.Transport)).Clone(),
+	}
+}
+
+// SetMediaHTTPClient sets the HTTP client used for media uploads and downloads, e.g. to set a custom transport.
+// Passing nil resets it to the default client.
+//
+// The media connection info (media_conn) is fetched through the websocket, so it's not affected by this.
+// SetProxy and SetSOCKSProxy don't modify the given client: they switch to a copy of it with a cloned transport.
+// If the given client doesn't use a *http.Transport, those methods can't apply proxies to it,
+// so the proxy must be configured in the client itself.
+func (cli *Client) SetMediaHTTPClient(h *http.Client) {
+	if h == nil {
+		h = newDefaultMediaHTTPClient()
+	}
+	cli.http = h
+}
+
 // ToggleProxyOnlyForLogin changes whether the proxy set with SetProxy or related methods
 // is only used for the pre-login websocket and not authenticated websockets.
 func (cli *Client) ToggleProxyOnlyForLogin(only bool) {
@@ -403,6 +547,13 @@ func (cli *Client) WaitForConnection(timeout time.Duration) bool {
 	return true
 }
 
+// SetWSDialer sets a custom websocket dialer for connecting to WhatsApp, e.g. to bind to a specific local address
+// or to use a custom proxy. When set, proxies configured with SetProxy or SetSOCKSProxy are not used for the websocket.
+//
+// The websocket and media traffic are configured separately, so they can go through different proxies or egress IPs:
+// use SetMediaHTTPClient (or SetProxy with SetProxyOptions.NoWebsocket) for media.
+//
+// Must be called before Connect() to take effect.
 func (cli *Client) SetWSDialer(dialer *websocket.Dialer) {
 	cli.wsDialer = dialer
 }
@@ -706,11 +857,23 @@ func (cli *Client) handleFrame(data []byte) {
 				cli.handlerQueue <- node
 			}()
 		}
-	} else if node.Tag != "ack" {
+	} else if node.Tag == "ack" {
+		cli.handleUnexpectedAck(node)
+	} else {
 		cli.Log.Debugf("Didn't handle WhatsApp node %s", node.Tag)
 	}
 }
 
+// handleUnexpectedAck handles acks for nodes that nobody is waiting for (e.g. receipts).
+// Successful acks are ignored, but errors are logged so that rejected stanzas don't go completely unnoticed.
+func (cli *Client) handleUnexpectedAck(node *waBinary.Node) {
+	ag := node.AttrGetter()
+	if errorCode := ag.OptionalInt("error"); errorCode != 0 {
+		cli.Log.Warnf("Server returned error %d in ack for %s %s (class %s)",
+			errorCode, ag.OptionalString("type"), ag.OptionalString("id"), ag.OptionalString("class"))
+	}
+}
+
 func stopAndDrainTimer(timer *time.Timer) {
 	if !timer.Stop() {
 		select {
@@ -778,16 +941,22 @@ func (cli *Client) sendNode(node waBinary.Node) error {
 
 func (cli *Client) dispatchEvent(evt interface{}) {
 	cli.eventHandlersLock.RLock()
-	defer func() {
-		cli.eventHandlersLock.RUnlock()
-		err := recover()
-		if err != nil {
-			cli.Log.Errorf("Event handler panicked while handling a %T: %v\n%s", evt, err, debug.Stack())
-		}
-	}()
+	defer cli.eventHandlersLock.RUnlock()
 	for _, handler := range cli.eventHandlers {
-		handler.fn(evt)
+		cli.callEventHandler(handler, evt)
+	}
+}
+
+func (cli *Client) callEventHandler(handler wrappedEventHandler, evt interface{}) {
+	if cli.RecoverEventHandlerPanics {
+		defer func() {
+			err := recover()
+			if err != nil {
+				cli.Log.Errorf("Event handler %d panicked while handling a %T: %v\n%s", handler.id, evt, err, debug.Stack())
+			}
+		}()
 	}
+	handler.fn(evt)
 }
 
 // ParseWebMessage parses a WebMessageInfo object into *events.Message to match what real-time messages have.
@@ -110,14 +110,31 @@ func (cli *Client) handleConnectFailure(node *waBinary.Node) {
 	case reason == 500 && message == "biz vname fetch error":
 		// These happen for business accounts randomly, also auto-reconnect
 	}
-	if reason == 403 {
+	if reason == events.ConnectFailureMainDeviceGone {
 		cli.Log.Debugf(
 			"Message for 403 connect failure: %s / %s",
 			ag.OptionalString("logout_message_header"),
 			ag.OptionalString("logout_message_subtext"),
 		)
 	}
-	if reason.IsLoggedOut() {
+	if reason == events.ConnectFailureBanned {
+		cli.Log.Warnf("Banned connect failure: %s", node.XMLString())
+		banned := &events.Banned{
+			Reason:  reason,
+			Expire:  time.Duration(ag.OptionalInt("expire")) * time.Second,
+			Header:  ag.OptionalString("logout_message_header"),
+			Subtext: ag.OptionalString("logout_message_subtext"),
+		}
+		// Bans also log out the device, so dispatch LoggedOut too for handlers that only listen to that.
+		go func() {
+			cli.dispatchEvent(banned)
+			cli.dispatchEvent(&events.LoggedOut{OnConnect: true, Reason: reason})
+		}()
+		err := cli.Store.Delete()
+		if err != nil {
+			cli.Log.Warnf("Failed to delete store after %d failure: %v", int(reason), err)
+		}
+	} else if reason.IsLoggedOut() {
 		cli.Log.Infof("Got %s connect failure, sending LoggedOut event and deleting session", reason)
 		go cli.dispatchEvent(&events.LoggedOut{OnConnect: true, Reason: reason})
 		err := cli.Store.Delete()
@@ -0,0 +1,82 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"testing"
+	"time"
+
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+type fakeDeviceContainer struct {
+	deleted bool
+}
+
+func (fdc *fakeDeviceContainer) PutDevice(_ *store.Device) error { return nil }
+func (fdc *fakeDeviceContainer) DeleteDevice(_ *store.Device) error {
+	fdc.deleted = true
+	return nil
+}
+
+func TestHandleConnectFailureBanned(t *testing.T) {
+	ownID := types.NewADJID("1111", 0, 5)
+	container := &fakeDeviceContainer{}
+	cli := &Client{Log: waLog.Noop, Store: &store.Device{ID: &ownID, Container: container}}
+	evts := make(chan any, 10)
+	cli.AddEventHandler(func(evt any) {
+		evts <- evt
+	})
+
+	cli.handleConnectFailure(&waBinary.Node{Tag: "failure", Attrs: waBinary.Attrs{
+		"reason":                "406",
+		"expire":                "3600",
+		"logout_message_header": "Banned",
+	}})
+
+	var received []any
+	timeout := time.After(time.Second)
+	for len(received) < 2 {
+		select {
+		case evt := <-evts:
+			received = append(received, evt)
+		case <-timeout:
+			t.Fatalf("expected 2 events, got %d", len(received))
+		}
+	}
+	banned, ok := received[0].(*events.Banned)
+	if !ok {
+		t.Fatalf("expected first event to be *events.Banned, got %T", received[0])
+	} else if banned.Reason != events.ConnectFailureBanned || banned.Expire != time.Hour || banned.Header != "Banned" {
+		t.Errorf("unexpected banned event: %+v", banned)
+	}
+	loggedOut, ok := received[1].(*events.LoggedOut)
+	if !ok {
+		t.Fatalf("expected second event to be *events.LoggedOut, got %T", received[1])
+	} else if !loggedOut.OnConnect || loggedOut.Reason != events.ConnectFailureBanned {
+		t.Errorf("unexpected logged out event: %+v", loggedOut)
+	}
+	var permanentDisconnects int
+	for _, evt := range received {
+		if _, ok := evt.(events.PermanentDisconnect); ok {
+			permanentDisconnects++
+		}
+	}
+	if permanentDisconnects != 1 {
+		t.Errorf("expected exactly one PermanentDisconnect event, got %d", permanentDisconnects)
+	}
+	if !container.deleted || cli.Store.ID != nil {
+		t.Error("expected device to be deleted from the store")
+	}
+	if events.ConnectFailureBanned.String() != "406: logged out for unknown reason" {
+		t.Errorf("unexpected String() for 406: %s", events.ConnectFailureBanned.String())
+	}
+}
@@ -7,18 +7,14 @@
 package whatsmeow
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
-	"encoding/base64"
-	"errors"
 	"fmt"
 	"io"
 	"os"
-	"strings"
-	"time"
 
 	"go.mau.fi/util/fallocate"
-	"go.mau.fi/util/retryafter"
 
 	"go.mau.fi/whatsmeow/proto/waMediaTransport"
 	"go.mau.fi/whatsmeow/util/cbcutil"
@@ -38,27 +34,13 @@ type File interface {
 //
 // This is otherwise identical to [Download], but writes the attachment to a file instead of returning it as a byte slice.
 func (cli *Client) DownloadToFile(msg DownloadableMessage, file File) error {
-	mediaType := GetMediaType(msg)
-	if mediaType == "" {
-		return fmt.Errorf("%w %T", ErrUnknownMediaType, msg)
-	}
-	urlable, ok := msg.(downloadableMessageWithURL)
-	var url string
-	var isWebWhatsappNetURL bool
-	if ok {
-		url = urlable.GetURL()
-		isWebWhatsappNetURL = strings.HasPrefix(url, "https://web.whatsapp.net")
-	}
-	if len(url) > 0 && !isWebWhatsappNetURL {
+	mediaType, url, err := cli.getMediaDownloadURL(msg)
+	if err != nil {
+		return err
+	} else if url != "" {
 		return cli.downloadAndDecryptToFile(url, msg.GetMediaKey(), mediaType, getSize(msg), msg.GetFileEncSHA256(), msg.GetFileSHA256(), file)
-	} else if len(msg.GetDirectPath()) > 0 {
-		return cli.DownloadMediaWithPathToFile(msg.GetDirectPath(), msg.GetFileEncSHA256(), msg.GetFileSHA256(), msg.GetMediaKey(), getSize(msg), mediaType, mediaTypeToMMSType[mediaType], file)
-	} else {
-		if isWebWhatsappNetURL {
-			cli.Log.Warnf("Got a media message with a web.whatsapp.net URL (%s) and no direct path", url)
-		}
-		return ErrNoURLPresent
 	}
+	return cli.DownloadMediaWithPathToFile(msg.GetDirectPath(), msg.GetFileEncSHA256(), msg.GetFileSHA256(), msg.GetMediaKey(), getSize(msg), mediaType, mediaTypeToMMSType[mediaType], file)
 }
 
 func (cli *Client) DownloadFBToFile(transport *waMediaTransport.WAMediaTransport_Integral, mediaType MediaType, file File) error {
@@ -66,26 +48,9 @@ func (cli *Client) DownloadFBToFile(transport *waMediaTransport.WAMediaTransport
 }
 
 func (cli *Client) DownloadMediaWithPathToFile(directPath string, encFileHash, fileHash, mediaKey []byte, fileLength int, mediaType MediaType, mmsType string, file File) error {
-	mediaConn, err := cli.refreshMediaConn(false)
-	if err != nil {
-		return fmt.Errorf("failed to refresh media connections: %w", err)
-	}
-	if len(mmsType) == 0 {
-		mmsType = mediaTypeToMMSType[mediaType]
-	}
-	for i, host := range mediaConn.Hosts {
-		// TODO omit hash for unencrypted media?
-		mediaURL := fmt.Sprintf("https://%s%s&hash=%s&mms-type=%s&__wa-mms=", host.Hostname, directPath, base64.URLEncoding.EncodeToString(encFileHash), mmsType)
-		err = cli.downloadAndDecryptToFile(mediaURL, mediaKey, mediaType, fileLength, encFileHash, fileHash, file)
-		if err == nil || errors.Is(err, ErrFileLengthMismatch) || errors.Is(err, ErrInvalidMediaSHA256) ||
-			errors.Is(err, ErrMediaDownloadFailedWith403) || errors.Is(err, ErrMediaDownloadFailedWith404) || errors.Is(err, ErrMediaDownloadFailedWith410) {
-			return err
-		} else if i >= len(mediaConn.Hosts)-1 {
-			return fmt.Errorf("failed to download media from last host: %w", err)
-		}
-		cli.Log.Warnf("Failed to download media: %s, trying with next host...", err)
-	}
-	return err
+	return cli.downloadFromMediaHosts(context.Background(), directPath, encFileHash, mediaType, mmsType, nil, func(mediaURL string) error {
+		return cli.downloadAndDecryptToFile(mediaURL, mediaKey, mediaType, fileLength, encFileHash, fileHash, file)
+	})
 }
 
 func (cli *Client) downloadAndDecryptToFile(url string, mediaKey []byte, appInfo MediaType, fileLength int, fileEncSHA256, fileSHA256 []byte, file File) error {
@@ -117,32 +82,26 @@ func (cli *Client) downloadAndDecryptToFile(url string, mediaKey []byte, appInfo
 }
 
 func (cli *Client) downloadPossiblyEncryptedMediaWithRetriesToFile(url string, checksum []byte, file File) (mac []byte, err error) {
-	for retryNum := 0; retryNum < 5; retryNum++ {
+	firstAttempt := true
+	err = cli.retryMediaDownload(context.Background(), nil, func() error {
+		if !firstAttempt {
+			if _, err = file.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to seek to start of file to retry download: %w", err)
+			}
+		}
+		firstAttempt = false
 		if checksum == nil {
 			_, _, err = cli.downloadMediaToFile(url, file)
 		} else {
 			mac, err = cli.downloadEncryptedMediaToFile(url, checksum, file)
 		}
-		if err == nil || !shouldRetryMediaDownload(err) {
-			return
-		}
-		retryDuration := time.Duration(retryNum+1) * time.Second
-		var httpErr DownloadHTTPError
-		if errors.As(err, &httpErr) {
-			retryDuration = retryafter.Parse(httpErr.Response.Header.Get("Retry-After"), retryDuration)
-		}
-		cli.Log.Warnf("Failed to download media due to network error: %v, retrying in %s...", err, retryDuration)
-		_, err = file.Seek(0, io.SeekStart)
-		if err != nil {
-			return nil, fmt.Errorf("failed to seek to start of file to retry download: %w", err)
-		}
-		time.Sleep(retryDuration)
-	}
+		return err
+	})
 	return
 }
 
 func (cli *Client) downloadMediaToFile(url string, file io.Writer) (int64, []byte, error) {
-	resp, err := cli.doMediaDownloadRequest(url)
+	resp, err := cli.doMediaDownloadRequest(context.Background(), url)
 	if err != nil {
 		return 0, nil, err
 	}
@@ -0,0 +1,156 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DownloadToWriter downloads the attachment from the given protobuf message and writes the decrypted data to w.
+//
+// Unlike [Download] and [DownloadToFile], the data is decrypted in chunks while it's being downloaded,
+// so memory usage stays constant regardless of the file size. The hmac and hashes can only be verified
+// after the whole file has been downloaded, so the last block of plaintext is held back until validation succeeds.
+// If validation fails, an error is returned and the data already written to w must be discarded.
+//
+// Network errors are only retried until the first bytes have been written to w.
+func (cli *Client) DownloadToWriter(msg DownloadableMessage, w io.Writer) error {
+	mediaType, url, err := cli.getMediaDownloadURL(msg)
+	if err != nil {
+		return err
+	} else if url != "" {
+		return cli.downloadAndDecryptToWriterWithRetries(url, msg.GetMediaKey(), mediaType, getSize(msg), msg.GetFileEncSHA256(), msg.GetFileSHA256(), &countingWriter{w: w})
+	}
+	return cli.DownloadMediaWithPathToWriter(msg.GetDirectPath(), msg.GetFileEncSHA256(), msg.GetFileSHA256(), msg.GetMediaKey(), getSize(msg), mediaType, mediaTypeToMMSType[mediaType], w)
+}
+
+// DownloadMediaWithPathToWriter is like DownloadMediaWithPath, but streams the decrypted data to w.
+// See [Client.DownloadToWriter] for details.
+func (cli *Client) DownloadMediaWithPathToWriter(directPath string, encFileHash, fileHash, mediaKey []byte, fileLength int, mediaType MediaType, mmsType string, w io.Writer) error {
+	cw := &countingWriter{w: w}
+	return cli.downloadFromMediaHosts(context.Background(), directPath, encFileHash, mediaType, mmsType, cw.isEmpty, func(mediaURL string) error {
+		return cli.downloadAndDecryptToWriterWithRetries(mediaURL, mediaKey, mediaType, fileLength, encFileHash, fileHash, cw)
+	})
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+func (cw *countingWriter) isEmpty() bool {
+	return cw.n == 0
+}
+
+// downloadAndDecryptToWriterWithRetries retries network errors only until something has been written to w,
+// because the data that was already written can't be taken back.
+func (cli *Client) downloadAndDecryptToWriterWithRetries(url string, mediaKey []byte, appInfo MediaType, fileLength int, fileEncSHA256, fileSHA256 []byte, w *countingWriter) error {
+	return cli.retryMediaDownload(context.Background(), w.isEmpty, func() error {
+		return cli.downloadAndDecryptToWriter(url, mediaKey, appInfo, fileLength, fileEncSHA256, fileSHA256, w)
+	})
+}
+
+func (cli *Client) downloadAndDecryptToWriter(url string, mediaKey []byte, appInfo MediaType, fileLength int, fileEncSHA256, fileSHA256 []byte, w io.Writer) error {
+	resp, err := cli.doMediaDownloadRequest(context.Background(), url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if mediaKey == nil && fileEncSHA256 == nil {
+		// Unencrypted media, just copy the downloaded data
+		_, err = io.Copy(w, resp.Body)
+		return err
+	}
+	return decryptMediaStream(resp.Body, mediaKey, appInfo, fileLength, fileEncSHA256, fileSHA256, w)
+}
+
+// decryptMediaStream decrypts and validates encrypted media from r, writing the plaintext to w.
+// The last ciphertext block and the mac are held back until EOF, so that the final plaintext block
+// is only written after the hmac and both hashes have been validated.
+func decryptMediaStream(r io.Reader, mediaKey []byte, appInfo MediaType, fileLength int, fileEncSHA256, fileSHA256 []byte, w io.Writer) error {
+	iv, cipherKey, macKey, _ := getMediaKeys(mediaKey, appInfo)
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return fmt.Errorf("failed to create cipher: %w", err)
+	}
+	cbc := cipher.NewCBCDecrypter(block, iv)
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(iv)
+	encHasher := sha256.New()
+	plainHasher := sha256.New()
+	r = io.TeeReader(r, encHasher)
+
+	const holdBack = mediaHMACLength + aes.BlockSize
+	buf := make([]byte, 0, 32*1024+holdBack)
+	var written int
+	for {
+		n, readErr := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if errors.Is(readErr, io.EOF) {
+			break
+		} else if readErr != nil {
+			return readErr
+		}
+		processable := (len(buf) - holdBack) / aes.BlockSize * aes.BlockSize
+		if processable <= 0 {
+			continue
+		}
+		chunk := buf[:processable]
+		mac.Write(chunk)
+		cbc.CryptBlocks(chunk, chunk)
+		plainHasher.Write(chunk)
+		if _, err = w.Write(chunk); err != nil {
+			return fmt.Errorf("failed to write decrypted data: %w", err)
+		}
+		written += processable
+		buf = buf[:copy(buf, buf[processable:])]
+	}
+
+	if len(buf) < holdBack {
+		return ErrTooShortFile
+	} else if (len(buf)-mediaHMACLength)%aes.BlockSize != 0 {
+		return fmt.Errorf("ciphertext length is not a multiple of the block size")
+	}
+	lastBlocks, fileMAC := buf[:len(buf)-mediaHMACLength], buf[len(buf)-mediaHMACLength:]
+	mac.Write(lastBlocks)
+	if len(fileEncSHA256) == 32 && !hmac.Equal(fileEncSHA256, encHasher.Sum(nil)) {
+		return ErrInvalidMediaEncSHA256
+	} else if !hmac.Equal(mac.Sum(nil)[:mediaHMACLength], fileMAC) {
+		return ErrInvalidMediaHMAC
+	}
+	cbc.CryptBlocks(lastBlocks, lastBlocks)
+	padding := int(lastBlocks[len(lastBlocks)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(lastBlocks[len(lastBlocks)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return fmt.Errorf("failed to decrypt file: invalid padding")
+	}
+	lastBlocks = lastBlocks[:len(lastBlocks)-padding]
+	plainHasher.Write(lastBlocks)
+	written += len(lastBlocks)
+	if fileLength >= 0 && written != fileLength {
+		return fmt.Errorf("%w: expected %d, got %d", ErrFileLengthMismatch, fileLength, written)
+	} else if len(fileSHA256) == 32 && !hmac.Equal(fileSHA256, plainHasher.Sum(nil)) {
+		return ErrInvalidMediaSHA256
+	}
+	if _, err = w.Write(lastBlocks); err != nil {
+		return fmt.Errorf("failed to write decrypted data: %w", err)
+	}
+	return nil
+}
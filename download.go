@@ -7,6 +7,7 @@
 package whatsmeow
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
@@ -25,6 +26,7 @@ import (
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/proto/waMediaTransport"
 	"go.mau.fi/whatsmeow/socket"
+	"go.mau.fi/whatsmeow/types/events"
 	"go.mau.fi/whatsmeow/util/cbcutil"
 	"go.mau.fi/whatsmeow/util/hkdfutil"
 )
@@ -131,22 +133,83 @@ func (cli *Client) DownloadAny(msg *waProto.Message) (data []byte, err error) {
 	if msg == nil {
 		return nil, ErrNothingDownloadableFound
 	}
+	downloadable := getDownloadableMessage(msg)
+	if downloadable == nil {
+		return nil, ErrNothingDownloadableFound
+	}
+	return cli.Download(downloadable)
+}
+
+// AutoDownloadMedia sets the media types that are downloaded automatically when receiving messages.
+// The downloaded data will be in the AutoDownloadedMedia field of the message event.
+// Attachments bigger than Client.AutoDownloadMaxSize are not downloaded. Calling this with an empty list disables automatic downloads.
+//
+// Downloads happen before the message event is dispatched, which delays handling of the following events.
+// Therefore, this should only be used for media types that are usually small, like images.
+func (cli *Client) AutoDownloadMedia(mediaTypes []MediaType) {
+	if len(mediaTypes) == 0 {
+		cli.autoDownloadTypes.Store(nil)
+		return
+	}
+	typeMap := make(map[MediaType]struct{}, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		typeMap[mediaType] = struct{}{}
+	}
+	cli.autoDownloadTypes.Store(&typeMap)
+}
+
+func getDownloadableMessage(msg *waProto.Message) DownloadableMessage {
 	switch {
 	case msg.ImageMessage != nil:
-		return cli.Download(msg.ImageMessage)
+		return msg.ImageMessage
 	case msg.VideoMessage != nil:
-		return cli.Download(msg.VideoMessage)
+		return msg.VideoMessage
 	case msg.AudioMessage != nil:
-		return cli.Download(msg.AudioMessage)
+		return msg.AudioMessage
 	case msg.DocumentMessage != nil:
-		return cli.Download(msg.DocumentMessage)
+		return msg.DocumentMessage
 	case msg.StickerMessage != nil:
-		return cli.Download(msg.StickerMessage)
+		return msg.StickerMessage
 	default:
-		return nil, ErrNothingDownloadableFound
+		return nil
 	}
 }
 
+const defaultAutoDownloadTimeout = 30 * time.Second
+
+func (cli *Client) autoDownload(evt *events.Message) {
+	typeMap := cli.autoDownloadTypes.Load()
+	if typeMap == nil {
+		return
+	}
+	downloadable := getDownloadableMessage(evt.Message)
+	if downloadable == nil {
+		return
+	}
+	mediaType := GetMediaType(downloadable)
+	if _, ok := (*typeMap)[mediaType]; !ok {
+		return
+	}
+	size := getSize(downloadable)
+	if size < 0 || size > cli.AutoDownloadMaxSize {
+		cli.Log.Debugf("Not auto-downloading %s in %s: size %d is unknown or over limit", mediaType, evt.Info.ID, size)
+		return
+	}
+	// This runs in the event handler queue, so don't let a stuck download block other events for long
+	timeout := cli.AutoDownloadTimeout
+	if timeout <= 0 {
+		timeout = defaultAutoDownloadTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	data, err := cli.download(ctx, downloadable, nil)
+	if err != nil {
+		cli.Log.Warnf("Failed to auto-download %s in %s: %v", mediaType, evt.Info.ID, err)
+		return
+	}
+	evt.AutoDownloadedMedia = data
+}
+
 func getSize(msg DownloadableMessage) int {
 	switch sized := msg.(type) {
 	case downloadableMessageWithLength:
@@ -199,9 +262,38 @@ func GetMediaType(msg DownloadableMessage) MediaType {
 //
 // You can also use DownloadAny to download the first non-nil sub-message.
 func (cli *Client) Download(msg DownloadableMessage) ([]byte, error) {
+	return cli.download(context.Background(), msg, nil)
+}
+
+// DownloadProgressFunc is called periodically while media is being downloaded.
+// The total is -1 if the server didn't send a Content-Length header.
+type DownloadProgressFunc func(done, total int64)
+
+// DownloadWithProgress downloads the attachment from the given protobuf message like Download,
+// but calls the given function periodically with the number of bytes downloaded so far.
+//
+// The function is called at least once after the download completes with the final size.
+// If the download is retried, the progress starts again from zero.
+func (cli *Client) DownloadWithProgress(msg DownloadableMessage, progress DownloadProgressFunc) ([]byte, error) {
+	return cli.download(context.Background(), msg, progress)
+}
+
+func (cli *Client) download(ctx context.Context, msg DownloadableMessage, progress DownloadProgressFunc) ([]byte, error) {
+	mediaType, url, err := cli.getMediaDownloadURL(msg)
+	if err != nil {
+		return nil, err
+	} else if url != "" {
+		return cli.downloadAndDecrypt(ctx, url, msg.GetMediaKey(), mediaType, getSize(msg), msg.GetFileEncSHA256(), msg.GetFileSHA256(), progress)
+	}
+	return cli.downloadMediaWithPath(ctx, msg.GetDirectPath(), msg.GetFileEncSHA256(), msg.GetFileSHA256(), msg.GetMediaKey(), getSize(msg), mediaType, mediaTypeToMMSType[mediaType], progress)
+}
+
+// getMediaDownloadURL returns the media type of the given message and the URL to download it from.
+// If the URL is empty, the media should be downloaded from the direct path using downloadFromMediaHosts.
+func (cli *Client) getMediaDownloadURL(msg DownloadableMessage) (MediaType, string, error) {
 	mediaType := GetMediaType(msg)
 	if mediaType == "" {
-		return nil, fmt.Errorf("%w %T", ErrUnknownMediaType, msg)
+		return "", "", fmt.Errorf("%w %T", ErrUnknownMediaType, msg)
 	}
 	urlable, ok := msg.(downloadableMessageWithURL)
 	var url string
@@ -211,14 +303,14 @@ func (cli *Client) Download(msg DownloadableMessage) ([]byte, error) {
 		isWebWhatsappNetURL = strings.HasPrefix(url, "https://web.whatsapp.net")
 	}
 	if len(url) > 0 && !isWebWhatsappNetURL {
-		return cli.downloadAndDecrypt(url, msg.GetMediaKey(), mediaType, getSize(msg), msg.GetFileEncSHA256(), msg.GetFileSHA256())
+		return mediaType, url, nil
 	} else if len(msg.GetDirectPath()) > 0 {
-		return cli.DownloadMediaWithPath(msg.GetDirectPath(), msg.GetFileEncSHA256(), msg.GetFileSHA256(), msg.GetMediaKey(), getSize(msg), mediaType, mediaTypeToMMSType[mediaType])
+		return mediaType, "", nil
 	} else {
 		if isWebWhatsappNetURL {
 			cli.Log.Warnf("Got a media message with a web.whatsapp.net URL (%s) and no direct path", url)
 		}
-		return nil, ErrNoURLPresent
+		return "", "", ErrNoURLPresent
 	}
 }
 
@@ -228,10 +320,25 @@ func (cli *Client) DownloadFB(transport *waMediaTransport.WAMediaTransport_Integ
 
 // DownloadMediaWithPath downloads an attachment by manually specifying the path and encryption details.
 func (cli *Client) DownloadMediaWithPath(directPath string, encFileHash, fileHash, mediaKey []byte, fileLength int, mediaType MediaType, mmsType string) (data []byte, err error) {
-	var mediaConn *MediaConn
-	mediaConn, err = cli.refreshMediaConn(false)
+	return cli.downloadMediaWithPath(context.Background(), directPath, encFileHash, fileHash, mediaKey, fileLength, mediaType, mmsType, nil)
+}
+
+func (cli *Client) downloadMediaWithPath(ctx context.Context, directPath string, encFileHash, fileHash, mediaKey []byte, fileLength int, mediaType MediaType, mmsType string, progress DownloadProgressFunc) (data []byte, err error) {
+	err = cli.downloadFromMediaHosts(ctx, directPath, encFileHash, mediaType, mmsType, nil, func(mediaURL string) error {
+		data, err = cli.downloadAndDecrypt(ctx, mediaURL, mediaKey, mediaType, fileLength, encFileHash, fileHash, progress)
+		return err
+	})
+	return
+}
+
+// downloadFromMediaHosts calls the given function with the URL of the direct path on each media host
+// until it succeeds or fails with an error that wouldn't be fixed by trying another host.
+//
+// If canRetry is set and returns false, other hosts won't be tried even if the error would allow it.
+func (cli *Client) downloadFromMediaHosts(ctx context.Context, directPath string, encFileHash []byte, mediaType MediaType, mmsType string, canRetry func() bool, download func(mediaURL string) error) error {
+	mediaConn, err := cli.refreshMediaConn(false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to refresh media connections: %w", err)
+		return fmt.Errorf("failed to refresh media connections: %w", err)
 	}
 	if len(mmsType) == 0 {
 		mmsType = mediaTypeToMMSType[mediaType]
@@ -239,22 +346,22 @@ func (cli *Client) DownloadMediaWithPath(directPath string, encFileHash, fileHas
 	for i, host := range mediaConn.Hosts {
 		// TODO omit hash for unencrypted media?
 		mediaURL := fmt.Sprintf("https://%s%s&hash=%s&mms-type=%s&__wa-mms=", host.Hostname, directPath, base64.URLEncoding.EncodeToString(encFileHash), mmsType)
-		data, err = cli.downloadAndDecrypt(mediaURL, mediaKey, mediaType, fileLength, encFileHash, fileHash)
-		if err == nil || errors.Is(err, ErrFileLengthMismatch) || errors.Is(err, ErrInvalidMediaSHA256) ||
+		err = download(mediaURL)
+		if err == nil || ctx.Err() != nil || (canRetry != nil && !canRetry()) || errors.Is(err, ErrFileLengthMismatch) || errors.Is(err, ErrInvalidMediaSHA256) ||
 			errors.Is(err, ErrMediaDownloadFailedWith403) || errors.Is(err, ErrMediaDownloadFailedWith404) || errors.Is(err, ErrMediaDownloadFailedWith410) {
-			return
+			return err
 		} else if i >= len(mediaConn.Hosts)-1 {
-			return nil, fmt.Errorf("failed to download media from last host: %w", err)
+			return fmt.Errorf("failed to download media from last host: %w", err)
 		}
 		cli.Log.Warnf("Failed to download media: %s, trying with next host...", err)
 	}
-	return
+	return err
 }
 
-func (cli *Client) downloadAndDecrypt(url string, mediaKey []byte, appInfo MediaType, fileLength int, fileEncSHA256, fileSHA256 []byte) (data []byte, err error) {
+func (cli *Client) downloadAndDecrypt(ctx context.Context, url string, mediaKey []byte, appInfo MediaType, fileLength int, fileEncSHA256, fileSHA256 []byte, progress DownloadProgressFunc) (data []byte, err error) {
 	iv, cipherKey, macKey, _ := getMediaKeys(mediaKey, appInfo)
 	var ciphertext, mac []byte
-	if ciphertext, mac, err = cli.downloadPossiblyEncryptedMediaWithRetries(url, fileEncSHA256); err != nil {
+	if ciphertext, mac, err = cli.downloadPossiblyEncryptedMediaWithRetries(ctx, url, fileEncSHA256, progress); err != nil {
 
 	} else if mediaKey == nil && fileEncSHA256 == nil && mac == nil {
 		// Unencrypted media, just return the downloaded data
@@ -284,14 +391,30 @@ func shouldRetryMediaDownload(err error) bool {
 		(errors.As(err, &httpErr) && retryafter.Should(httpErr.StatusCode, true))
 }
 
-func (cli *Client) downloadPossiblyEncryptedMediaWithRetries(url string, checksum []byte) (file, mac []byte, err error) {
-	for retryNum := 0; retryNum < 5; retryNum++ {
+func (cli *Client) mediaDownloadAttempts() int {
+	return max(cli.MediaDownloadAttempts, 1)
+}
+
+func (cli *Client) downloadPossiblyEncryptedMediaWithRetries(ctx context.Context, url string, checksum []byte, progress DownloadProgressFunc) (file, mac []byte, err error) {
+	err = cli.retryMediaDownload(ctx, nil, func() error {
 		if checksum == nil {
-			file, err = cli.downloadMedia(url)
+			file, err = cli.downloadMedia(ctx, url, progress)
 		} else {
-			file, mac, err = cli.downloadEncryptedMedia(url, checksum)
+			file, mac, err = cli.downloadEncryptedMedia(ctx, url, checksum, progress)
 		}
-		if err == nil || !shouldRetryMediaDownload(err) {
+		return err
+	})
+	return
+}
+
+// retryMediaDownload calls the given function up to MediaDownloadAttempts times, waiting between attempts,
+// for as long as it fails with network errors or retryable HTTP statuses.
+//
+// If canRetry is set and returns false, the download isn't retried even if the error would allow it.
+func (cli *Client) retryMediaDownload(ctx context.Context, canRetry func() bool, download func() error) (err error) {
+	for retryNum := 0; retryNum < cli.mediaDownloadAttempts(); retryNum++ {
+		err = download()
+		if err == nil || !shouldRetryMediaDownload(err) || (canRetry != nil && !canRetry()) {
 			return
 		}
 		retryDuration := time.Duration(retryNum+1) * time.Second
@@ -300,13 +423,17 @@ func (cli *Client) downloadPossiblyEncryptedMediaWithRetries(url string, checksu
 			retryDuration = retryafter.Parse(httpErr.Response.Header.Get("Retry-After"), retryDuration)
 		}
 		cli.Log.Warnf("Failed to download media due to network error: %v, retrying in %s...", err, retryDuration)
-		time.Sleep(retryDuration)
+		select {
+		case <-time.After(retryDuration):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 	return
 }
 
-func (cli *Client) doMediaDownloadRequest(url string) (*http.Response, error) {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+func (cli *Client) doMediaDownloadRequest(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare request: %w", err)
 	}
@@ -327,20 +454,45 @@ func (cli *Client) doMediaDownloadRequest(url string) (*http.Response, error) {
 	return resp, nil
 }
 
-func (cli *Client) downloadMedia(url string) ([]byte, error) {
-	resp, err := cli.doMediaDownloadRequest(url)
+// progressReader calls the progress function after every read, but at most every progressInterval.
+type progressReader struct {
+	io.Reader
+	progress   DownloadProgressFunc
+	done       int64
+	total      int64
+	lastReport time.Time
+}
+
+const progressInterval = 100 * time.Millisecond
+
+func (pr *progressReader) Read(p []byte) (n int, err error) {
+	n, err = pr.Reader.Read(p)
+	pr.done += int64(n)
+	if err != nil || time.Since(pr.lastReport) >= progressInterval {
+		pr.lastReport = time.Now()
+		pr.progress(pr.done, pr.total)
+	}
+	return
+}
+
+func (cli *Client) downloadMedia(ctx context.Context, url string, progress DownloadProgressFunc) ([]byte, error) {
+	resp, err := cli.doMediaDownloadRequest(ctx, url)
 	if err != nil {
 		return nil, err
 	}
-	data, err := io.ReadAll(resp.Body)
+	var body io.Reader = resp.Body
+	if progress != nil {
+		body = &progressReader{Reader: resp.Body, progress: progress, total: resp.ContentLength, lastReport: time.Now()}
+	}
+	data, err := io.ReadAll(body)
 	_ = resp.Body.Close()
 	return data, err
 }
 
 const mediaHMACLength = 10
 
-func (cli *Client) downloadEncryptedMedia(url string, checksum []byte) (file, mac []byte, err error) {
-	data, err := cli.downloadMedia(url)
+func (cli *Client) downloadEncryptedMedia(ctx context.Context, url string, checksum []byte, progress DownloadProgressFunc) (file, mac []byte, err error) {
+	data, err := cli.downloadMedia(ctx, url, progress)
 	if err != nil {
 		return
 	} else if len(data) <= mediaHMACLength {
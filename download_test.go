@@ -0,0 +1,179 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"go.mau.fi/util/random"
+	"google.golang.org/protobuf/proto"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.mau.fi/whatsmeow/util/cbcutil"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+func encryptTestMedia(t *testing.T, plaintext []byte) (mediaKey, ciphertext, fileSHA256, fileEncSHA256 []byte) {
+	mediaKey = random.Bytes(32)
+	iv, cipherKey, macKey, _ := getMediaKeys(mediaKey, MediaVideo)
+	var buf bytes.Buffer
+	fileSHA256, fileEncSHA256, _, _, err := cbcutil.EncryptStream(cipherKey, iv, macKey, bytes.NewReader(plaintext), &buf)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	return mediaKey, buf.Bytes(), fileSHA256, fileEncSHA256
+}
+
+func TestDecryptMediaStream(t *testing.T) {
+	for _, size := range []int{0, 1, 15, 16, 17, 32*1024 - 1, 32 * 1024, 100000} {
+		plaintext := random.Bytes(size)
+		mediaKey, ciphertext, fileSHA256, fileEncSHA256 := encryptTestMedia(t, plaintext)
+		var out bytes.Buffer
+		err := decryptMediaStream(iotest.HalfReader(bytes.NewReader(ciphertext)), mediaKey, MediaVideo, size, fileEncSHA256, fileSHA256, &out)
+		if err != nil {
+			t.Fatalf("size %d: failed to decrypt: %v", size, err)
+		} else if !bytes.Equal(out.Bytes(), plaintext) {
+			t.Fatalf("size %d: decrypted data doesn't match", size)
+		}
+	}
+}
+
+func TestDecryptMediaStreamInvalid(t *testing.T) {
+	// Use a length that isn't a multiple of the block size, so that the held back block contains plaintext
+	plaintext := random.Bytes(50005)
+	mediaKey, ciphertext, fileSHA256, fileEncSHA256 := encryptTestMedia(t, plaintext)
+	tampered := bytes.Clone(ciphertext)
+	tampered[100] ^= 1
+
+	testCases := []struct {
+		name       string
+		ciphertext []byte
+		encSHA256  []byte
+		length     int
+		expected   error
+	}{
+		{"tampered ciphertext", tampered, fileEncSHA256, len(plaintext), ErrInvalidMediaEncSHA256},
+		{"tampered ciphertext without enc hash", tampered, nil, len(plaintext), ErrInvalidMediaHMAC},
+		{"wrong length", ciphertext, fileEncSHA256, len(plaintext) + 1, ErrFileLengthMismatch},
+		{"too short", ciphertext[:20], nil, len(plaintext), ErrTooShortFile},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := decryptMediaStream(bytes.NewReader(tc.ciphertext), mediaKey, MediaVideo, tc.length, tc.encSHA256, fileSHA256, &out)
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, err)
+			} else if out.Len() >= len(plaintext) {
+				t.Fatalf("expected final block to be held back, but %d bytes were written", out.Len())
+			}
+		})
+	}
+}
+
+func TestDownloadAndDecryptVerifiesHashes(t *testing.T) {
+	plaintext := random.Bytes(5000)
+	mediaKey, ciphertext, fileSHA256, fileEncSHA256 := encryptTestMedia(t, plaintext)
+	corrupted := bytes.Clone(ciphertext)
+	corrupted[10] ^= 0xff
+	var served []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(served)
+	}))
+	defer srv.Close()
+	cli := &Client{http: srv.Client(), Log: waLog.Noop}
+
+	served = ciphertext
+	data, err := cli.downloadAndDecrypt(context.Background(), srv.URL, mediaKey, MediaVideo, len(plaintext), fileEncSHA256, fileSHA256, nil)
+	if err != nil {
+		t.Fatalf("failed to download valid media: %v", err)
+	} else if !bytes.Equal(data, plaintext) {
+		t.Fatal("downloaded data doesn't match")
+	}
+	_, err = cli.downloadAndDecrypt(context.Background(), srv.URL, mediaKey, MediaVideo, len(plaintext), fileEncSHA256, random.Bytes(32), nil)
+	if !errors.Is(err, ErrInvalidMediaSHA256) {
+		t.Errorf("expected %v for wrong plaintext hash, got %v", ErrInvalidMediaSHA256, err)
+	}
+
+	served = corrupted
+	_, err = cli.downloadAndDecrypt(context.Background(), srv.URL, mediaKey, MediaVideo, len(plaintext), fileEncSHA256, fileSHA256, nil)
+	if !errors.Is(err, ErrInvalidMediaEncSHA256) {
+		t.Errorf("expected %v for corrupted ciphertext, got %v", ErrInvalidMediaEncSHA256, err)
+	} else if !errors.Is(err, ErrMediaHashMismatch) {
+		t.Errorf("expected corrupted ciphertext error to wrap %v", ErrMediaHashMismatch)
+	}
+	_, err = cli.downloadAndDecrypt(context.Background(), srv.URL, mediaKey, MediaVideo, len(plaintext), nil, fileSHA256, nil)
+	if !errors.Is(err, ErrInvalidMediaHMAC) {
+		t.Errorf("expected %v for corrupted ciphertext without enc hash, got %v", ErrInvalidMediaHMAC, err)
+	}
+}
+
+func TestAutoDownloadTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Never respond, the request should be cancelled by the client
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	cli := &Client{http: srv.Client(), Log: waLog.Noop, AutoDownloadMaxSize: 1024, AutoDownloadTimeout: 50 * time.Millisecond}
+	cli.AutoDownloadMedia([]MediaType{MediaVideo})
+
+	evt := &events.Message{Message: &waProto.Message{VideoMessage: &waProto.VideoMessage{
+		URL:        proto.String(srv.URL),
+		MediaKey:   random.Bytes(32),
+		FileLength: proto.Uint64(100),
+	}}}
+	start := time.Now()
+	cli.autoDownload(evt)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("auto download took %s, expected it to time out after 50ms", elapsed)
+	}
+	if evt.AutoDownloadedMedia != nil {
+		t.Error("expected no downloaded media after timeout")
+	}
+}
+
+func TestRetryMediaDownload(t *testing.T) {
+	retryable := DownloadHTTPError{Response: &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}}
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	testCases := []struct {
+		name          string
+		ctx           context.Context
+		canRetry      func() bool
+		err           error
+		expectedErr   error
+		expectedCalls int
+	}{
+		{"success", context.Background(), nil, nil, nil, 1},
+		{"non-retryable error", context.Background(), nil, ErrMediaDownloadFailedWith404, ErrMediaDownloadFailedWith404, 1},
+		{"retry not allowed", context.Background(), func() bool { return false }, retryable, retryable, 1},
+		{"cancelled while waiting", cancelledCtx, nil, retryable, context.Canceled, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := &Client{Log: waLog.Noop, MediaDownloadAttempts: 3}
+			var calls int
+			err := cli.retryMediaDownload(tc.ctx, tc.canRetry, func() error {
+				calls++
+				return tc.err
+			})
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("expected error %v, got %v", tc.expectedErr, err)
+			}
+			if calls != tc.expectedCalls {
+				t.Errorf("expected %d calls, got %d", tc.expectedCalls, calls)
+			}
+		})
+	}
+}
@@ -91,7 +91,7 @@ var (
 	ErrBusinessMessageLinkNotFound = errors.New("that business message link does not exist or has been revoked")
 	// ErrContactQRLinkNotFound is returned by ResolveContactQRLink if the link doesn't exist or has been revoked.
 	ErrContactQRLinkNotFound = errors.New("that contact QR link does not exist or has been revoked")
-	// ErrInvalidImageFormat is returned by SetGroupPhoto if the given photo is not in the correct format.
+	// ErrInvalidImageFormat is returned by SetGroupPhoto and SetProfilePicture if the given photo is not in the correct format.
 	ErrInvalidImageFormat = errors.New("the given data is not a valid image")
 	// ErrMediaNotAvailableOnPhone is returned by DecryptMediaRetryNotification if the given event contains error code 2.
 	ErrMediaNotAvailableOnPhone = errors.New("media no longer available on phone")
@@ -101,6 +101,15 @@ var (
 	ErrInvalidDisappearingTimer = errors.New("invalid disappearing timer provided")
 )
 
+// Errors that can be wrapped in events.UndecryptableMessage or returned by Client.DecryptMessage. Errors from libsignal are also wrapped,
+// see the signalerror package in go.mau.fi/libsignal.
+var (
+	ErrEmptyPlaintext = errors.New("plaintext is empty")
+	ErrInvalidPadding = errors.New("plaintext doesn't have expected padding")
+
+	ErrNoDecryptableContent = errors.New("message doesn't contain decryptable content")
+)
+
 // Some errors that Client.SendMessage can return
 var (
 	ErrBroadcastListUnsupported = errors.New("sending to non-status broadcast lists is not yet supported")
@@ -110,6 +119,14 @@ var (
 	ErrInvalidInlineBotID       = errors.New("invalid inline bot ID")
 )
 
+// Errors that the message building helpers like Client.SendLocation, Client.SendContact and Client.SetReplyContext can return
+var (
+	ErrInvalidLocation = errors.New("invalid location")
+	ErrInvalidVCard    = errors.New("invalid vcard")
+
+	ErrMessageHasNoContextInfo = errors.New("message type doesn't support context info")
+)
+
 type DownloadHTTPError struct {
 	*http.Response
 }
@@ -123,6 +140,11 @@ func (dhe DownloadHTTPError) Is(other error) bool {
 	return errors.As(other, &otherDHE) && dhe.StatusCode == otherDHE.StatusCode
 }
 
+// ErrMediaHashMismatch is wrapped by ErrInvalidMediaHMAC, ErrInvalidMediaEncSHA256 and ErrInvalidMediaSHA256,
+// so callers can check for any kind of corrupted or tampered download with errors.Is. Truncated downloads fail with
+// ErrInvalidMediaEncSHA256 if the ciphertext hash is known, or ErrTooShortFile/ErrInvalidMediaHMAC otherwise.
+var ErrMediaHashMismatch = errors.New("media hash mismatch")
+
 // Some errors that Client.Download can return
 var (
 	ErrMediaDownloadFailedWith403 = DownloadHTTPError{Response: &http.Response{StatusCode: 403}}
@@ -131,9 +153,9 @@ var (
 	ErrNoURLPresent               = errors.New("no url present")
 	ErrFileLengthMismatch         = errors.New("file length does not match")
 	ErrTooShortFile               = errors.New("file too short")
-	ErrInvalidMediaHMAC           = errors.New("invalid media hmac")
-	ErrInvalidMediaEncSHA256      = errors.New("hash of media ciphertext doesn't match")
-	ErrInvalidMediaSHA256         = errors.New("hash of media plaintext doesn't match")
+	ErrInvalidMediaHMAC           = fmt.Errorf("%w: invalid media hmac", ErrMediaHashMismatch)
+	ErrInvalidMediaEncSHA256      = fmt.Errorf("%w: hash of media ciphertext doesn't match", ErrMediaHashMismatch)
+	ErrInvalidMediaSHA256         = fmt.Errorf("%w: hash of media plaintext doesn't match", ErrMediaHashMismatch)
 	ErrUnknownMediaType           = errors.New("unknown media type")
 	ErrNothingDownloadableFound   = errors.New("didn't find any attachments in message")
 )
@@ -144,6 +166,13 @@ var (
 	ErrNotPollUpdateMessage          = errors.New("given message isn't a poll update message")
 )
 
+// Errors that can be returned by Client.ImportSession
+var (
+	ErrUnsupportedSessionExportVersion = errors.New("unsupported session export version")
+	ErrInvalidSessionExport            = errors.New("invalid session export")
+	ErrImportWhileLoggedIn             = errors.New("can't import session into a store that already contains a device")
+)
+
 type wrappedIQError struct {
 	HumanError error
 	IQError    error
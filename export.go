@@ -0,0 +1,241 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/util/keys"
+)
+
+// sessionExportVersion is the current version of the format produced by Client.ExportSession.
+const sessionExportVersion = 1
+
+type exportedSession struct {
+	Version int `json:"version"`
+
+	ID              types.JID `json:"id"`
+	RegistrationID  uint32    `json:"registration_id"`
+	NoiseKey        []byte    `json:"noise_key"`
+	IdentityKey     []byte    `json:"identity_key"`
+	SignedPreKey    []byte    `json:"signed_pre_key"`
+	SignedPreKeyID  uint32    `json:"signed_pre_key_id"`
+	SignedPreKeySig []byte    `json:"signed_pre_key_sig"`
+	AdvSecretKey    []byte    `json:"adv_secret_key"`
+	Account         []byte    `json:"account"`
+
+	Platform     string    `json:"platform,omitempty"`
+	BusinessName string    `json:"business_name,omitempty"`
+	PushName     string    `json:"push_name,omitempty"`
+	FacebookUUID uuid.UUID `json:"facebook_uuid"`
+
+	Sessions   map[string][]byte `json:"sessions,omitempty"`
+	Identities map[string][]byte `json:"identities,omitempty"`
+	PreKeys    map[uint32][]byte `json:"pre_keys,omitempty"`
+}
+
+type sessionExporter interface {
+	GetAllSessions() (map[string][]byte, error)
+	GetAllIdentities() (map[string][32]byte, error)
+}
+
+type preKeyExporter interface {
+	GetAllUploadedPreKeys() ([]*keys.PreKey, error)
+}
+
+type preKeyImporter interface {
+	PutUploadedPreKey(key *keys.PreKey) error
+}
+
+// ExportSession serializes the logged-in device so that it can be moved to another machine
+// with ImportSession without pairing again.
+//
+// The export contains the device keys and account info. Signal sessions, identity keys and
+// prekeys that have been uploaded to the server are included if the store supports listing them
+// (sqlstore does). The prekeys are needed to decrypt the first message from contacts who fetched
+// one of them before the move. Sender keys and app state keys are not included: they're recovered
+// through the normal retry and app state key request mechanisms.
+//
+// The output contains private keys, so it must be stored as securely as the database itself.
+// The client should not be used on the old machine after exporting.
+func (cli *Client) ExportSession() ([]byte, error) {
+	if cli == nil {
+		return nil, ErrClientIsNil
+	} else if cli.Store.ID == nil {
+		return nil, ErrNotLoggedIn
+	}
+	account, err := proto.Marshal(cli.Store.Account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal account: %w", err)
+	}
+	export := &exportedSession{
+		Version: sessionExportVersion,
+
+		ID:              *cli.Store.ID,
+		RegistrationID:  cli.Store.RegistrationID,
+		NoiseKey:        cli.Store.NoiseKey.Priv[:],
+		IdentityKey:     cli.Store.IdentityKey.Priv[:],
+		SignedPreKey:    cli.Store.SignedPreKey.Priv[:],
+		SignedPreKeyID:  cli.Store.SignedPreKey.KeyID,
+		SignedPreKeySig: cli.Store.SignedPreKey.Signature[:],
+		AdvSecretKey:    cli.Store.AdvSecretKey,
+		Account:         account,
+
+		Platform:     cli.Store.Platform,
+		BusinessName: cli.Store.BusinessName,
+		PushName:     cli.Store.PushName,
+		FacebookUUID: cli.Store.FacebookUUID,
+	}
+	if exporter, ok := cli.Store.Sessions.(sessionExporter); ok {
+		export.Sessions, err = exporter.GetAllSessions()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sessions: %w", err)
+		}
+		var identities map[string][32]byte
+		identities, err = exporter.GetAllIdentities()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get identities: %w", err)
+		}
+		export.Identities = make(map[string][]byte, len(identities))
+		for address, identity := range identities {
+			export.Identities[address] = identity[:]
+		}
+	} else {
+		cli.Log.Warnf("Session store doesn't support listing sessions, exporting only device keys")
+	}
+	if exporter, ok := cli.Store.PreKeys.(preKeyExporter); ok {
+		var preKeys []*keys.PreKey
+		preKeys, err = exporter.GetAllUploadedPreKeys()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get prekeys: %w", err)
+		}
+		export.PreKeys = make(map[uint32][]byte, len(preKeys))
+		for _, key := range preKeys {
+			export.PreKeys[key.KeyID] = key.Priv[:]
+		}
+	} else {
+		cli.Log.Warnf("Prekey store doesn't support listing prekeys, not exporting prekeys")
+	}
+	return json.Marshal(export)
+}
+
+// ImportSession loads a session exported with ExportSession into the client's store.
+//
+// The store must be empty (i.e. a new device from Container.NewDevice) and the client must not be connected.
+// After importing, Connect can be called normally.
+func (cli *Client) ImportSession(data []byte) error {
+	if cli == nil {
+		return ErrClientIsNil
+	} else if cli.IsConnected() {
+		return ErrAlreadyConnected
+	} else if cli.Store.ID != nil {
+		return ErrImportWhileLoggedIn
+	}
+	var export exportedSession
+	err := json.Unmarshal(data, &export)
+	if err != nil {
+		return fmt.Errorf("failed to parse session export: %w", err)
+	} else if export.Version != sessionExportVersion {
+		return fmt.Errorf("%w %d", ErrUnsupportedSessionExportVersion, export.Version)
+	} else if export.ID.IsEmpty() || len(export.NoiseKey) != 32 || len(export.IdentityKey) != 32 ||
+		len(export.SignedPreKey) != 32 || len(export.SignedPreKeySig) != 64 {
+		return ErrInvalidSessionExport
+	}
+	identities := make(map[string][32]byte, len(export.Identities))
+	for address, identity := range export.Identities {
+		if len(identity) != 32 {
+			return fmt.Errorf("%w: invalid identity key length for %s", ErrInvalidSessionExport, address)
+		}
+		identities[address] = *(*[32]byte)(identity)
+	}
+	preKeys := make([]*keys.PreKey, 0, len(export.PreKeys))
+	for keyID, priv := range export.PreKeys {
+		if len(priv) != 32 {
+			return fmt.Errorf("%w: invalid prekey length for %d", ErrInvalidSessionExport, keyID)
+		}
+		preKeys = append(preKeys, &keys.PreKey{
+			KeyPair: *keys.NewKeyPairFromPrivateKey(*(*[32]byte)(priv)),
+			KeyID:   keyID,
+		})
+	}
+	var account waProto.ADVSignedDeviceIdentity
+	err = proto.Unmarshal(export.Account, &account)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal account: %w", err)
+	}
+
+	// Everything is validated, so start modifying the store. If anything fails after this point,
+	// the device is deleted and the previous store state is restored, so the import can be retried.
+	previous := *cli.Store
+	cli.Store.ID = &export.ID
+	cli.Store.RegistrationID = export.RegistrationID
+	cli.Store.NoiseKey = keys.NewKeyPairFromPrivateKey(*(*[32]byte)(export.NoiseKey))
+	cli.Store.IdentityKey = keys.NewKeyPairFromPrivateKey(*(*[32]byte)(export.IdentityKey))
+	cli.Store.SignedPreKey = &keys.PreKey{
+		KeyPair:   *keys.NewKeyPairFromPrivateKey(*(*[32]byte)(export.SignedPreKey)),
+		KeyID:     export.SignedPreKeyID,
+		Signature: (*[64]byte)(export.SignedPreKeySig),
+	}
+	cli.Store.AdvSecretKey = export.AdvSecretKey
+	cli.Store.Account = &account
+	cli.Store.Platform = export.Platform
+	cli.Store.BusinessName = export.BusinessName
+	cli.Store.PushName = export.PushName
+	cli.Store.FacebookUUID = export.FacebookUUID
+	err = cli.Store.Save()
+	if err != nil {
+		*cli.Store = previous
+		return fmt.Errorf("failed to save imported device: %w", err)
+	}
+	err = cli.importSignalData(identities, export.Sessions, preKeys)
+	if err != nil {
+		deleteErr := cli.Store.Delete()
+		if deleteErr != nil {
+			cli.Log.Errorf("Failed to delete partially imported device %s: %v", export.ID, deleteErr)
+		}
+		*cli.Store = previous
+		return err
+	}
+	cli.Log.Infof("Imported session for %s with %d sessions, %d identities and %d prekeys", export.ID, len(export.Sessions), len(identities), len(preKeys))
+	return nil
+}
+
+func (cli *Client) importSignalData(identities map[string][32]byte, sessions map[string][]byte, preKeys []*keys.PreKey) error {
+	for address, identity := range identities {
+		err := cli.Store.Identities.PutIdentity(address, identity)
+		if err != nil {
+			return fmt.Errorf("failed to import identity of %s: %w", address, err)
+		}
+	}
+	for address, session := range sessions {
+		err := cli.Store.Sessions.PutSession(address, session)
+		if err != nil {
+			return fmt.Errorf("failed to import session with %s: %w", address, err)
+		}
+	}
+	if len(preKeys) == 0 {
+		return nil
+	}
+	importer, ok := cli.Store.PreKeys.(preKeyImporter)
+	if !ok {
+		cli.Log.Warnf("Prekey store doesn't support importing prekeys, skipping %d prekeys", len(preKeys))
+		return nil
+	}
+	for _, key := range preKeys {
+		err := importer.PutUploadedPreKey(key)
+		if err != nil {
+			return fmt.Errorf("failed to import prekey %d: %w", key.KeyID, err)
+		}
+	}
+	return nil
+}
@@ -241,6 +241,11 @@ func (cli *Client) UpdateGroupRequestParticipants(jid types.JID, participantChan
 // The avatar should be a JPEG photo, other formats may be rejected with ErrInvalidImageFormat.
 // The bytes can be nil to remove the photo. Returns the new picture ID.
 func (cli *Client) SetGroupPhoto(jid types.JID, avatar []byte) (string, error) {
+	return cli.setPicture(jid, avatar)
+}
+
+// setPicture updates the picture of the given target, or the own profile picture if the target is empty.
+func (cli *Client) setPicture(target types.JID, avatar []byte) (string, error) {
 	var content interface{}
 	if avatar != nil {
 		content = []waBinary.Node{{
@@ -253,7 +258,7 @@ func (cli *Client) SetGroupPhoto(jid types.JID, avatar []byte) (string, error) {
 		Namespace: "w:profile:picture",
 		Type:      iqSet,
 		To:        types.ServerJID,
-		Target:    jid,
+		Target:    target,
 		Content:   content,
 	})
 	if errors.Is(err, ErrIQNotAcceptable) {
@@ -544,6 +549,8 @@ func (cli *Client) getGroupInfo(ctx context.Context, jid types.JID, lockParticip
 	if err != nil {
 		return groupInfo, err
 	}
+	// The server only returns group info to participants
+	cli.setLeftGroup(jid, false)
 	if lockParticipantCache {
 		cli.groupParticipantsCacheLock.Lock()
 		defer cli.groupParticipantsCacheLock.Unlock()
@@ -552,7 +559,10 @@ func (cli *Client) getGroupInfo(ctx context.Context, jid types.JID, lockParticip
 	for i, part := range groupInfo.Participants {
 		participants[i] = part.JID
 	}
-	cli.groupParticipantsCache[jid] = participants
+	cli.groupParticipantsCache[jid] = groupMembersCache{
+		members:              participants,
+		participantVersionID: groupInfo.ParticipantVersionID,
+	}
 	return groupInfo, nil
 }
 
@@ -565,7 +575,7 @@ func (cli *Client) getGroupMembers(ctx context.Context, jid types.JID) ([]types.
 			return nil, err
 		}
 	}
-	return cli.groupParticipantsCache[jid], nil
+	return cli.groupParticipantsCache[jid].members, nil
 }
 
 func parseParticipant(childAG *waBinary.AttrUtility, child *waBinary.Node) types.GroupParticipant {
@@ -829,8 +839,23 @@ func (cli *Client) parseGroupChange(node *waBinary.Node) (*events.GroupInfo, err
 	return &evt, nil
 }
 
+// invalidateParticipantCaches removes the cached member list of the given chat as well as the cached device lists
+// of its members, so they will be fetched again on the next send.
+func (cli *Client) invalidateParticipantCaches(jid types.JID) {
+	cli.groupParticipantsCacheLock.Lock()
+	cached := cli.groupParticipantsCache[jid]
+	delete(cli.groupParticipantsCache, jid)
+	cli.groupParticipantsCacheLock.Unlock()
+	cli.userDevicesCacheLock.Lock()
+	delete(cli.userDevicesCache, jid)
+	for _, member := range cached.members {
+		delete(cli.userDevicesCache, member)
+	}
+	cli.userDevicesCacheLock.Unlock()
+}
+
 func (cli *Client) updateGroupParticipantCache(evt *events.GroupInfo) {
-	if len(evt.Join) == 0 && len(evt.Leave) == 0 {
+	if len(evt.Join) == 0 && len(evt.Leave) == 0 && evt.ParticipantVersionID == "" {
 		return
 	}
 	cli.groupParticipantsCacheLock.Lock()
@@ -839,37 +864,94 @@ func (cli *Client) updateGroupParticipantCache(evt *events.GroupInfo) {
 	if !ok {
 		return
 	}
+	if evt.PrevParticipantVersionID != "" && cached.participantVersionID != "" &&
+		evt.PrevParticipantVersionID != cached.participantVersionID {
+		// We've missed some membership changes, so patching the cached list isn't safe
+		cli.Log.Debugf("Participant version of %s changed from %s to %s, but cache has %s. Invalidating cache.",
+			evt.JID, evt.PrevParticipantVersionID, evt.ParticipantVersionID, cached.participantVersionID)
+		delete(cli.groupParticipantsCache, evt.JID)
+		return
+	}
+	members := cached.members
 Outer:
 	for _, jid := range evt.Join {
-		for _, existingJID := range cached {
+		for _, existingJID := range members {
 			if jid == existingJID {
 				continue Outer
 			}
 		}
-		cached = append(cached, jid)
+		members = append(members, jid)
 	}
 	for _, jid := range evt.Leave {
-		for i, existingJID := range cached {
+		for i, existingJID := range members {
 			if existingJID == jid {
-				cached[i] = cached[len(cached)-1]
-				cached = cached[:len(cached)-1]
+				members[i] = members[len(members)-1]
+				members = members[:len(members)-1]
 				break
 			}
 		}
 	}
+	cached.members = members
+	if evt.ParticipantVersionID != "" {
+		cached.participantVersionID = evt.ParticipantVersionID
+	}
 	cli.groupParticipantsCache[evt.JID] = cached
 }
 
+func (cli *Client) updateLeftGroups(evt *events.GroupInfo) {
+	ownID := cli.getOwnID().ToNonAD()
+	if ownID.IsEmpty() {
+		return
+	}
+	for _, jid := range evt.Leave {
+		if jid == ownID {
+			cli.setLeftGroup(evt.JID, true)
+			return
+		}
+	}
+	for _, jid := range evt.Join {
+		if jid == ownID {
+			cli.setLeftGroup(evt.JID, false)
+			return
+		}
+	}
+}
+
+func (cli *Client) setLeftGroup(jid types.JID, left bool) {
+	cli.leftGroupsLock.Lock()
+	if left {
+		cli.leftGroups[jid] = struct{}{}
+	} else {
+		delete(cli.leftGroups, jid)
+	}
+	cli.leftGroupsLock.Unlock()
+}
+
+// hasLeftGroup returns true if a group change notification said that we left or were removed from the given group
+// while this client was running.
+func (cli *Client) hasLeftGroup(jid types.JID) bool {
+	cli.leftGroupsLock.RLock()
+	_, left := cli.leftGroups[jid]
+	cli.leftGroupsLock.RUnlock()
+	return left
+}
+
 func (cli *Client) parseGroupNotification(node *waBinary.Node) (interface{}, error) {
 	children := node.GetChildren()
 	if len(children) == 1 && children[0].Tag == "create" {
-		return cli.parseGroupCreate(&children[0])
+		joinedGroup, err := cli.parseGroupCreate(&children[0])
+		if err != nil {
+			return nil, err
+		}
+		cli.setLeftGroup(joinedGroup.JID, false)
+		return joinedGroup, nil
 	} else {
 		groupChange, err := cli.parseGroupChange(node)
 		if err != nil {
 			return nil, err
 		}
 		cli.updateGroupParticipantCache(groupChange)
+		cli.updateLeftGroups(groupChange)
 		return groupChange, nil
 	}
 }
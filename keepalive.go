@@ -15,6 +15,7 @@ import (
 	"go.mau.fi/whatsmeow/types/events"
 )
 
+// The keepalive settings are global and apply to all clients in the process.
 var (
 	// KeepAliveResponseDeadline specifies the duration to wait for a response to websocket keepalive pings.
 	KeepAliveResponseDeadline = 10 * time.Second
@@ -61,7 +62,18 @@ func (cli *Client) keepAliveLoop(ctx context.Context) {
 	}
 }
 
+// LastKeepAliveLatency returns the round-trip time of the most recent successful keepalive ping.
+//
+// The value is zero if no keepalive ping has succeeded yet.
+func (cli *Client) LastKeepAliveLatency() time.Duration {
+	if cli == nil {
+		return 0
+	}
+	return time.Duration(cli.lastKeepAliveLatency.Load())
+}
+
 func (cli *Client) sendKeepAlive(ctx context.Context) (isSuccess, shouldContinue bool) {
+	start := time.Now()
 	respCh, err := cli.sendIQAsync(infoQuery{
 		Namespace: "w:p",
 		Type:      "get",
@@ -74,6 +86,7 @@ func (cli *Client) sendKeepAlive(ctx context.Context) (isSuccess, shouldContinue
 	select {
 	case <-respCh:
 		// All good
+		cli.lastKeepAliveLatency.Store(int64(time.Since(start)))
 		return true, true
 	case <-time.After(KeepAliveResponseDeadline):
 		cli.Log.Warnf("Keepalive timed out")
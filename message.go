@@ -9,6 +9,7 @@ package whatsmeow
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -31,11 +32,15 @@ import (
 	"go.mau.fi/whatsmeow/store"
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
 )
 
+// pbSerializer is stateless, so it is safe to share between multiple clients.
 var pbSerializer = store.SignalProtobufSerializer
 
 func (cli *Client) handleEncryptedMessage(node *waBinary.Node) {
+	// Always ack, even if the message can't be parsed, so that the server doesn't keep redelivering it
+	go cli.sendAck(node)
 	info, err := cli.parseMessageInfo(node)
 	if err != nil {
 		cli.Log.Warnf("Failed to parse message: %v", err)
@@ -46,7 +51,6 @@ func (cli *Client) handleEncryptedMessage(node *waBinary.Node) {
 		if len(info.PushName) > 0 && info.PushName != "-" {
 			go cli.updatePushName(info.Sender, info, info.PushName)
 		}
-		go cli.sendAck(node)
 		if info.Sender.Server == types.NewsletterServer {
 			cli.handlePlaintextMessage(info, node)
 		} else {
@@ -71,7 +75,7 @@ func (cli *Client) parseMessageSource(node *waBinary.Node, requireParticipant bo
 		} else {
 			source.Sender = ag.OptionalJIDOrEmpty("participant")
 		}
-		if source.Sender.User == clientID.User {
+		if source.Sender.IsSameUser(clientID) {
 			source.IsFromMe = true
 		}
 		if from.Server == types.BroadcastServer {
@@ -81,7 +85,7 @@ func (cli *Client) parseMessageSource(node *waBinary.Node, requireParticipant bo
 		source.Chat = from
 		source.Sender = from
 		// TODO IsFromMe?
-	} else if from.User == clientID.User {
+	} else if from.IsSameUser(clientID) {
 		source.IsFromMe = true
 		source.Sender = from
 		recipient := ag.OptionalJID("recipient")
@@ -149,6 +153,8 @@ func (cli *Client) parseMessageInfo(node *waBinary.Node) (*types.MessageInfo, er
 	info.Category = ag.OptionalString("category")
 	info.Type = ag.OptionalString("type")
 	info.Edit = types.EditAttribute(ag.OptionalString("edit"))
+	_, info.IsOffline = node.Attrs["offline"]
+	info.OfflineIndex = ag.OptionalInt("offline")
 	if !ag.OK() {
 		return nil, ag.Error()
 	}
@@ -207,6 +213,12 @@ func (cli *Client) handlePlaintextMessage(info *types.MessageInfo, node *waBinar
 		Info:       *info,
 		RawMessage: &msg,
 	}
+	if cli.IncludeRawMessageBytes {
+		evt.RawMessageBytes = plaintextBody
+	}
+	if cli.IncludeSourceNode {
+		evt.SourceNode = node
+	}
 	meta, ok := node.GetOptionalChildByTag("meta")
 	if ok {
 		evt.NewsletterMeta = &events.NewsletterMessageMeta{
@@ -219,15 +231,30 @@ func (cli *Client) handlePlaintextMessage(info *types.MessageInfo, node *waBinar
 }
 
 func (cli *Client) decryptMessages(info *types.MessageInfo, node *waBinary.Node) {
+	// Only build the field maps if the logger supports fields, as this runs for every message
+	msgLog := cli.Log
+	_, logSupportsFields := cli.Log.(waLog.FieldLogger)
+	if logSupportsFields {
+		msgLog = waLog.WithFields(cli.Log, map[string]any{
+			"message_id": info.ID,
+			"from":       info.Sender.String(),
+			"chat":       info.Chat.String(),
+		})
+	}
+	if cli.isDuplicateMessage(info) {
+		msgLog.Debugf("Ignoring duplicate message %s from %s", info.ID, info.SourceString())
+		go cli.sendMessageReceipt(info)
+		return
+	}
 	if len(node.GetChildrenByTag("unavailable")) > 0 && len(node.GetChildrenByTag("enc")) == 0 {
-		cli.Log.Warnf("Unavailable message %s from %s", info.ID, info.SourceString())
+		msgLog.Warnf("Unavailable message %s from %s", info.ID, info.SourceString())
 		go cli.delayedRequestMessageFromPhone(info)
 		cli.dispatchEvent(&events.UndecryptableMessage{Info: *info, IsUnavailable: true})
 		return
 	}
 
 	children := node.GetChildren()
-	cli.Log.Debugf("Decrypting message from %s", info.SourceString())
+	msgLog.Debugf("Decrypting message from %s", info.SourceString())
 	handled := false
 	containsDirectMsg := false
 	for _, child := range children {
@@ -239,6 +266,10 @@ func (cli *Client) decryptMessages(info *types.MessageInfo, node *waBinary.Node)
 		if !ok {
 			continue
 		}
+		log := msgLog
+		if logSupportsFields {
+			log = waLog.WithFields(msgLog, map[string]any{"enc_type": encType})
+		}
 		var decrypted []byte
 		var err error
 		if encType == "pkmsg" || encType == "msg" {
@@ -258,7 +289,7 @@ func (cli *Client) decryptMessages(info *types.MessageInfo, node *waBinary.Node)
 
 			messageSecret, err := cli.Store.MsgSecrets.GetMessageSecret(info.Chat, targetSenderJID, info.MsgMetaInfo.TargetID)
 			if err != nil || messageSecret == nil {
-				cli.Log.Warnf("Error getting message secret for bot msg with id %s", node.AttrGetter().String("id"))
+				log.Warnf("Error getting message secret for bot msg with id %s", node.AttrGetter().String("id"))
 				continue
 			}
 
@@ -268,7 +299,7 @@ func (cli *Client) decryptMessages(info *types.MessageInfo, node *waBinary.Node)
 
 			err = proto.Unmarshal(byteContents, &msMsg)
 			if err != nil {
-				cli.Log.Warnf("Error decoding MessageSecretMesage protobuf %v", err)
+				log.Warnf("Error decoding MessageSecretMesage protobuf %v", err)
 				continue
 			}
 
@@ -283,18 +314,19 @@ func (cli *Client) decryptMessages(info *types.MessageInfo, node *waBinary.Node)
 			// step 4: decrypt and voila
 			decrypted, err = cli.decryptBotMessage(messageSecret, &msMsg, messageID, targetSenderJID, info)
 		} else {
-			cli.Log.Warnf("Unhandled encrypted message (type %s) from %s", encType, info.SourceString())
+			log.Warnf("Unhandled encrypted message (type %s) from %s", encType, info.SourceString())
 			continue
 		}
 
 		if err != nil {
-			cli.Log.Warnf("Error decrypting message from %s: %v", info.SourceString(), err)
+			log.Warnf("Error decrypting message from %s: %v", info.SourceString(), err)
 			isUnavailable := encType == "skmsg" && !containsDirectMsg && errors.Is(err, signalerror.ErrNoSenderKeyForUser)
 			go cli.sendRetryReceipt(node, info, isUnavailable)
 			cli.dispatchEvent(&events.UndecryptableMessage{
 				Info:            *info,
 				IsUnavailable:   isUnavailable,
 				DecryptFailMode: events.DecryptFailMode(ag.OptionalString("decrypt-fail")),
+				Error:           err,
 			})
 			return
 		}
@@ -306,23 +338,152 @@ func (cli *Client) decryptMessages(info *types.MessageInfo, node *waBinary.Node)
 		case 2:
 			err = proto.Unmarshal(decrypted, &msg)
 			if err != nil {
-				cli.Log.Warnf("Error unmarshaling decrypted message from %s: %v", info.SourceString(), err)
+				log.Warnf("Error unmarshaling decrypted message from %s: %v", info.SourceString(), err)
 				continue
 			}
-			cli.handleDecryptedMessage(info, &msg, retryCount)
+			cli.handleDecryptedMessage(info, node, &msg, decrypted, retryCount)
 			handled = true
 		case 3:
 			handled = cli.handleDecryptedArmadillo(info, decrypted, retryCount)
 		default:
-			cli.Log.Warnf("Unknown version %d in decrypted message from %s", ag.Int("v"), info.SourceString())
+			log.Warnf("Unknown version %d in decrypted message from %s", ag.Int("v"), info.SourceString())
 		}
 	}
 	if handled {
+		cli.markMessageSeen(info)
 		go cli.sendMessageReceipt(info)
 	}
 }
 
-func (cli *Client) clearUntrustedIdentity(target types.JID) {
+// DefaultMessageDedupSize is the default value of Client.MessageDedupSize.
+const DefaultMessageDedupSize = 1024
+
+type seenMessageKey struct {
+	Chat   types.JID
+	Sender types.JID
+	ID     types.MessageID
+}
+
+func (cli *Client) isDuplicateMessage(info *types.MessageInfo) bool {
+	if cli.MessageDedupSize <= 0 {
+		return false
+	}
+	cli.seenMessagesLock.Lock()
+	_, seen := cli.seenMessagesMap[seenMessageKey{info.Chat, info.Sender, info.ID}]
+	cli.seenMessagesLock.Unlock()
+	return seen
+}
+
+func (cli *Client) markMessageSeen(info *types.MessageInfo) {
+	if cli.MessageDedupSize <= 0 {
+		return
+	}
+	cli.seenMessagesLock.Lock()
+	defer cli.seenMessagesLock.Unlock()
+	if len(cli.seenMessagesList) != cli.MessageDedupSize {
+		cli.seenMessagesList = make([]seenMessageKey, cli.MessageDedupSize)
+		cli.seenMessagesMap = make(map[seenMessageKey]struct{}, cli.MessageDedupSize)
+		cli.seenMessagesPtr = 0
+	}
+	key := seenMessageKey{info.Chat, info.Sender, info.ID}
+	if _, alreadySeen := cli.seenMessagesMap[key]; alreadySeen {
+		return
+	}
+	if cli.seenMessagesList[cli.seenMessagesPtr].ID != "" {
+		delete(cli.seenMessagesMap, cli.seenMessagesList[cli.seenMessagesPtr])
+	}
+	cli.seenMessagesMap[key] = struct{}{}
+	cli.seenMessagesList[cli.seenMessagesPtr] = key
+	cli.seenMessagesPtr = (cli.seenMessagesPtr + 1) % len(cli.seenMessagesList)
+}
+
+// DecryptMessage decrypts the enc children of a raw message node and returns the parsed message,
+// without sending receipts, retry receipts or message events. If info is nil, it's parsed from the node.
+//
+// This is meant for tools that process captured or archived nodes. Note that decrypting advances the stored
+// signal sessions the same way as normal message handling, so each node can only be decrypted once, and nodes
+// that have already been handled by the client can't be decrypted again.
+//
+// Sender key distribution messages in the pkmsg/msg parts are stored so that the skmsg part of the same node
+// can be decrypted. If the node contains several parts, the message from the last part is returned.
+// Only v2 (non-Armadillo) messages are supported.
+func (cli *Client) DecryptMessage(node *waBinary.Node, info *types.MessageInfo) (*waE2E.Message, error) {
+	if cli == nil {
+		return nil, ErrClientIsNil
+	}
+	if info == nil {
+		var err error
+		info, err = cli.parseMessageInfo(node)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse message info: %w", err)
+		}
+	}
+	var result *waE2E.Message
+	for _, child := range node.GetChildrenByTag("enc") {
+		ag := child.AttrGetter()
+		encType := ag.OptionalString("type")
+		var decrypted []byte
+		var err error
+		if ag.Int("v") != 2 {
+			return nil, fmt.Errorf("%w: unsupported version %d", ErrNoDecryptableContent, ag.Int("v"))
+		} else if encType == "pkmsg" || encType == "msg" {
+			decrypted, err = cli.decryptDM(&child, info.Sender, encType == "pkmsg")
+		} else if info.IsGroup && encType == "skmsg" {
+			decrypted, err = cli.decryptGroupMsg(&child, info.Sender, info.Chat)
+		} else {
+			return nil, fmt.Errorf("%w: unsupported type %q", ErrNoDecryptableContent, encType)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s part: %w", encType, err)
+		}
+		var msg waE2E.Message
+		err = proto.Unmarshal(decrypted, &msg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s part: %w", encType, err)
+		}
+		if skdm := msg.GetSenderKeyDistributionMessage(); skdm != nil && info.IsGroup {
+			cli.handleSenderKeyDistributionMessage(info.Chat, info.Sender, skdm.AxolotlSenderKeyDistributionMessage)
+		}
+		result = &msg
+	}
+	if result == nil {
+		return nil, ErrNoDecryptableContent
+	}
+	return result, nil
+}
+
+func (cli *Client) clearUntrustedIdentity(target types.JID, newIdentity [32]byte) {
+	oldIdentity := cli.getStoredIdentity(target)
+	cli.deleteIdentityAndSession(target)
+	cli.dispatchEvent(&events.IdentityChange{
+		JID:            target,
+		Timestamp:      time.Now(),
+		Implicit:       true,
+		NewIdentityKey: newIdentity[:],
+		OldIdentityKey: oldIdentity,
+		Trusted:        true,
+	})
+}
+
+type identityGetter interface {
+	GetIdentity(address string) ([]byte, error)
+}
+
+// getStoredIdentity returns the stored identity key of the given device,
+// or nil if it's not known or the identity store doesn't support reading identities.
+func (cli *Client) getStoredIdentity(target types.JID) []byte {
+	getter, ok := cli.Store.Identities.(identityGetter)
+	if !ok {
+		return nil
+	}
+	identity, err := getter.GetIdentity(target.SignalAddress().String())
+	if err != nil {
+		cli.Log.Warnf("Failed to get stored identity of %s: %v", target, err)
+	}
+	return identity
+}
+
+func (cli *Client) deleteIdentityAndSession(target types.JID) {
 	err := cli.Store.Identities.DeleteIdentity(target.SignalAddress().String())
 	if err != nil {
 		cli.Log.Warnf("Failed to delete untrusted identity of %s from store: %v", target, err)
@@ -331,7 +492,17 @@ func (cli *Client) clearUntrustedIdentity(target types.JID) {
 	if err != nil {
 		cli.Log.Warnf("Failed to delete session with %s (untrusted identity) from store: %v", target, err)
 	}
-	cli.dispatchEvent(&events.IdentityChange{JID: target, Timestamp: time.Now(), Implicit: true})
+}
+
+// TrustIdentity deletes the stored identity and session of the given device, so that the next
+// prekey message from the device is accepted with its new identity key.
+//
+// This is only needed if AutoTrustIdentity is disabled. In that case, an events.IdentityChange with
+// Trusted set to false is emitted when a message fails to decrypt because of an unknown identity key.
+// After verifying the new key, call this with the JID from the event. Retry receipts will make the sender
+// resend the failed messages.
+func (cli *Client) TrustIdentity(jid types.JID) {
+	cli.deleteIdentityAndSession(jid)
 }
 
 func (cli *Client) decryptDM(child *waBinary.Node, from types.JID, isPreKey bool) ([]byte, error) {
@@ -345,15 +516,31 @@ func (cli *Client) decryptDM(child *waBinary.Node, from types.JID, isPreKey bool
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse prekey message: %w", err)
 		}
+		hadSession := cli.Store.ContainsSession(from.SignalAddress())
 		plaintext, _, err = cipher.DecryptMessageReturnKey(preKeyMsg)
-		if cli.AutoTrustIdentity && errors.Is(err, signalerror.ErrUntrustedIdentity) {
-			cli.Log.Warnf("Got %v error while trying to decrypt prekey message from %s, clearing stored identity and retrying", err, from)
-			cli.clearUntrustedIdentity(from)
-			plaintext, _, err = cipher.DecryptMessageReturnKey(preKeyMsg)
+		if errors.Is(err, signalerror.ErrUntrustedIdentity) {
+			newIdentity := preKeyMsg.IdentityKey().PublicKey().PublicKey()
+			if cli.AutoTrustIdentity {
+				cli.Log.Warnf("Got %v error while trying to decrypt prekey message from %s, clearing stored identity and retrying", err, from)
+				cli.clearUntrustedIdentity(from, newIdentity)
+				hadSession = false
+				plaintext, _, err = cipher.DecryptMessageReturnKey(preKeyMsg)
+			} else {
+				cli.dispatchEvent(&events.IdentityChange{
+					JID:            from,
+					Timestamp:      time.Now(),
+					Implicit:       true,
+					NewIdentityKey: newIdentity[:],
+					OldIdentityKey: cli.getStoredIdentity(from),
+				})
+			}
 		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to decrypt prekey message: %w", err)
 		}
+		if !hadSession {
+			cli.dispatchEvent(&events.SessionEstablished{JID: from, Timestamp: time.Now()})
+		}
 	} else {
 		msg, err := protocol.NewSignalMessageFromBytes(content, pbSerializer.SignalMessage)
 		if err != nil {
@@ -367,7 +554,7 @@ func (cli *Client) decryptDM(child *waBinary.Node, from types.JID, isPreKey bool
 	if child.AttrGetter().Int("v") == 3 {
 		return plaintext, nil
 	}
-	return unpadMessage(plaintext)
+	return unpadMessage(plaintext, cli.CheckPadding)
 }
 
 func (cli *Client) decryptGroupMsg(child *waBinary.Node, from types.JID, chat types.JID) ([]byte, error) {
@@ -387,23 +574,23 @@ func (cli *Client) decryptGroupMsg(child *waBinary.Node, from types.JID, chat ty
 	if child.AttrGetter().Int("v") == 3 {
 		return plaintext, nil
 	}
-	return unpadMessage(plaintext)
+	return unpadMessage(plaintext, cli.CheckPadding)
 }
 
-const checkPadding = true
-
 func isValidPadding(plaintext []byte) bool {
 	lastByte := plaintext[len(plaintext)-1]
 	expectedPadding := bytes.Repeat([]byte{lastByte}, int(lastByte))
 	return bytes.HasSuffix(plaintext, expectedPadding)
 }
 
-func unpadMessage(plaintext []byte) ([]byte, error) {
+func unpadMessage(plaintext []byte, checkPadding bool) ([]byte, error) {
 	if len(plaintext) == 0 {
-		return nil, fmt.Errorf("plaintext is empty")
+		return nil, ErrEmptyPlaintext
 	}
 	if checkPadding && !isValidPadding(plaintext) {
-		return nil, fmt.Errorf("plaintext doesn't have expected padding")
+		return nil, ErrInvalidPadding
+	} else if int(plaintext[len(plaintext)-1]) > len(plaintext) {
+		return nil, fmt.Errorf("%w: padding is longer than the plaintext", ErrInvalidPadding)
 	}
 	return plaintext[:len(plaintext)-int(plaintext[len(plaintext)-1])], nil
 }
@@ -452,7 +639,7 @@ func (cli *Client) handleHistorySyncNotificationLoop() {
 
 func (cli *Client) handleHistorySyncNotification(notif *waProto.HistorySyncNotification) {
 	var historySync waProto.HistorySync
-	if data, err := cli.Download(notif); err != nil {
+	if data, err := cli.download(context.Background(), notif, cli.HistorySyncDownloadProgressHook); err != nil {
 		cli.Log.Errorf("Failed to download history sync data: %v", err)
 	} else if reader, err := zlib.NewReader(bytes.NewReader(data)); err != nil {
 		cli.Log.Errorf("Failed to create zlib reader for history sync data: %v", err)
@@ -651,10 +838,18 @@ func (cli *Client) storeHistoricalMessageSecrets(conversations []*waProto.Conver
 	}
 }
 
-func (cli *Client) handleDecryptedMessage(info *types.MessageInfo, msg *waProto.Message, retryCount int) {
+func (cli *Client) handleDecryptedMessage(info *types.MessageInfo, node *waBinary.Node, msg *waProto.Message, decrypted []byte, retryCount int) {
 	cli.processProtocolParts(info, msg)
 	evt := &events.Message{Info: *info, RawMessage: msg, RetryCount: retryCount}
-	cli.dispatchEvent(evt.UnwrapRaw())
+	if cli.IncludeRawMessageBytes {
+		evt.RawMessageBytes = decrypted
+	}
+	if cli.IncludeSourceNode {
+		evt.SourceNode = node
+	}
+	evt.UnwrapRaw()
+	cli.autoDownload(evt)
+	cli.dispatchEvent(evt)
 }
 
 func (cli *Client) sendProtocolMessageReceipt(id types.MessageID, msgType types.ReceiptType) {
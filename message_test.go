@@ -0,0 +1,241 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"go.mau.fi/libsignal/ecc"
+	"go.mau.fi/libsignal/keys/identity"
+	"go.mau.fi/libsignal/protocol"
+	"go.mau.fi/libsignal/signalerror"
+	"go.mau.fi/libsignal/util/optional"
+	"go.mau.fi/util/random"
+
+	waBinary "go.mau.fi/whatsmeow/binary"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+func TestParseMessageSource(t *testing.T) {
+	ownID := types.NewADJID("1111", 0, 5)
+	cli := &Client{Store: &store.Device{ID: &ownID}}
+
+	otherUser := types.NewADJID("2222", 0, 3)
+	group := types.NewJID("123456-789", types.GroupServer)
+	broadcastList := types.NewJID("1234567890", types.BroadcastServer)
+	newsletter := types.NewJID("120363000000000000", types.NewsletterServer)
+
+	testCases := []struct {
+		name     string
+		attrs    waBinary.Attrs
+		expected types.MessageSource
+		isStatus bool
+		isList   bool
+	}{{
+		name:     "direct message",
+		attrs:    waBinary.Attrs{"from": otherUser},
+		expected: types.MessageSource{Chat: otherUser.ToNonAD(), Sender: otherUser},
+	}, {
+		name:  "own message from other device",
+		attrs: waBinary.Attrs{"from": types.NewADJID("1111", 0, 2), "recipient": otherUser.ToNonAD()},
+		expected: types.MessageSource{
+			Chat:     otherUser.ToNonAD(),
+			Sender:   types.NewADJID("1111", 0, 2),
+			IsFromMe: true,
+		},
+	}, {
+		name:     "group message",
+		attrs:    waBinary.Attrs{"from": group, "participant": otherUser},
+		expected: types.MessageSource{Chat: group, Sender: otherUser, IsGroup: true},
+	}, {
+		name:     "status broadcast",
+		attrs:    waBinary.Attrs{"from": types.StatusBroadcastJID, "participant": otherUser},
+		expected: types.MessageSource{Chat: types.StatusBroadcastJID, Sender: otherUser, IsGroup: true},
+		isStatus: true,
+	}, {
+		name:  "broadcast list",
+		attrs: waBinary.Attrs{"from": broadcastList, "participant": otherUser, "recipient": otherUser.ToNonAD()},
+		expected: types.MessageSource{
+			Chat:               broadcastList,
+			Sender:             otherUser,
+			IsGroup:            true,
+			BroadcastListOwner: otherUser.ToNonAD(),
+		},
+		isList: true,
+	}, {
+		name:     "newsletter",
+		attrs:    waBinary.Attrs{"from": newsletter},
+		expected: types.MessageSource{Chat: newsletter, Sender: newsletter},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source, err := cli.parseMessageSource(&waBinary.Node{Tag: "message", Attrs: tc.attrs}, true)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if source != tc.expected {
+				t.Fatalf("expected %+v, got %+v", tc.expected, source)
+			} else if source.Chat.IsBroadcastList() != tc.isList {
+				t.Errorf("expected IsBroadcastList to be %t", tc.isList)
+			} else if (source.Chat == types.StatusBroadcastJID) != tc.isStatus {
+				t.Errorf("expected status broadcast to be %t", tc.isStatus)
+			}
+		})
+	}
+
+	_, err := cli.parseMessageSource(&waBinary.Node{Tag: "message", Attrs: waBinary.Attrs{"from": group}}, true)
+	if err == nil {
+		t.Error("expected error for group message without participant")
+	}
+}
+
+func TestPadMessageRoundTrip(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		plaintext := random.Bytes(i % 64)
+		padded := padMessage(bytes.Clone(plaintext))
+		padLen := len(padded) - len(plaintext)
+		if padLen < 1 || padLen > 15 {
+			t.Fatalf("invalid padding length %d", padLen)
+		}
+		unpadded, err := unpadMessage(padded, true)
+		if err != nil {
+			t.Fatalf("failed to unpad message: %v", err)
+		} else if !bytes.Equal(unpadded, plaintext) {
+			t.Fatalf("unpadded message doesn't match: expected %x, got %x", plaintext, unpadded)
+		}
+	}
+}
+
+func TestUnpadMessageInvalid(t *testing.T) {
+	testCases := []struct {
+		name      string
+		plaintext []byte
+		expected  error
+	}{
+		{"empty", []byte{}, ErrEmptyPlaintext},
+		{"nil", nil, ErrEmptyPlaintext},
+		{"padding longer than plaintext", []byte{5, 5, 5}, ErrInvalidPadding},
+		{"mismatching padding bytes", []byte{'a', 1, 3, 3}, ErrInvalidPadding},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := unpadMessage(tc.plaintext, true)
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+
+	_, err := unpadMessage([]byte{1, 2, 5}, false)
+	if !errors.Is(err, ErrInvalidPadding) {
+		t.Errorf("expected %v for under-length input without padding check, got %v", ErrInvalidPadding, err)
+	}
+}
+
+type fakeUntrustedIdentityStore struct {
+	stored  []byte
+	deleted bool
+}
+
+func (fis *fakeUntrustedIdentityStore) PutIdentity(_ string, _ [32]byte) error { return nil }
+func (fis *fakeUntrustedIdentityStore) DeleteAllIdentities(_ string) error     { return nil }
+func (fis *fakeUntrustedIdentityStore) DeleteIdentity(_ string) error {
+	fis.deleted = true
+	return nil
+}
+func (fis *fakeUntrustedIdentityStore) IsTrustedIdentity(_ string, _ [32]byte) (bool, error) {
+	return false, nil
+}
+func (fis *fakeUntrustedIdentityStore) GetIdentity(_ string) ([]byte, error) {
+	return fis.stored, nil
+}
+
+type fakeEmptySessionStore struct{}
+
+func (fakeEmptySessionStore) GetSession(_ string) ([]byte, error) { return nil, nil }
+func (fakeEmptySessionStore) HasSession(_ string) (bool, error)   { return false, nil }
+func (fakeEmptySessionStore) PutSession(_ string, _ []byte) error { return nil }
+func (fakeEmptySessionStore) DeleteAllSessions(_ string) error    { return nil }
+func (fakeEmptySessionStore) DeleteSession(_ string) error        { return nil }
+
+func makeTestPreKeyMessage(t *testing.T) ([]byte, [32]byte) {
+	identityPair, err := ecc.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate identity key: %v", err)
+	}
+	basePair, err := ecc.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate base key: %v", err)
+	}
+	ratchetPair, err := ecc.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("failed to generate ratchet key: %v", err)
+	}
+	senderIdentity := identity.NewKey(identityPair.PublicKey())
+	receiverIdentity := identity.NewKey(ratchetPair.PublicKey())
+	signalMsg, err := protocol.NewSignalMessage(
+		protocol.CurrentVersion, 0, 0, random.Bytes(32), ratchetPair.PublicKey(), random.Bytes(32),
+		senderIdentity, receiverIdentity, pbSerializer.SignalMessage,
+	)
+	if err != nil {
+		t.Fatalf("failed to create signal message: %v", err)
+	}
+	preKeyMsg, err := protocol.NewPreKeySignalMessage(
+		protocol.CurrentVersion, 1, optional.NewOptionalUint32(1), 1, basePair.PublicKey(), senderIdentity,
+		signalMsg, pbSerializer.PreKeySignalMessage, pbSerializer.SignalMessage,
+	)
+	if err != nil {
+		t.Fatalf("failed to create prekey message: %v", err)
+	}
+	return preKeyMsg.Serialize(), identityPair.PublicKey().PublicKey()
+}
+
+func TestDecryptDMUntrustedIdentityNoAutoTrust(t *testing.T) {
+	oldIdentity := random.Bytes(32)
+	identities := &fakeUntrustedIdentityStore{stored: oldIdentity}
+	cli := &Client{
+		Log:               waLog.Noop,
+		Store:             &store.Device{Log: waLog.Noop, Identities: identities, Sessions: fakeEmptySessionStore{}},
+		AutoTrustIdentity: false,
+	}
+	var received []*events.IdentityChange
+	cli.AddEventHandler(func(evt any) {
+		if identityChange, ok := evt.(*events.IdentityChange); ok {
+			received = append(received, identityChange)
+		}
+	})
+
+	content, newIdentity := makeTestPreKeyMessage(t)
+	from := types.NewADJID("1111", 0, 1)
+	_, err := cli.decryptDM(&waBinary.Node{Tag: "enc", Attrs: waBinary.Attrs{"v": "2", "type": "pkmsg"}, Content: content}, from, true)
+	if !errors.Is(err, signalerror.ErrUntrustedIdentity) {
+		t.Fatalf("expected %v, got %v", signalerror.ErrUntrustedIdentity, err)
+	}
+	if identities.deleted {
+		t.Error("stored identity was deleted even though AutoTrustIdentity is disabled")
+	}
+	if len(received) != 1 {
+		t.Fatalf("expected 1 identity change event, got %d", len(received))
+	}
+	evt := received[0]
+	if evt.Trusted {
+		t.Error("expected Trusted to be false")
+	}
+	if !evt.Implicit || evt.JID != from {
+		t.Errorf("unexpected identity change event: %+v", evt)
+	}
+	if !bytes.Equal(evt.OldIdentityKey, oldIdentity) {
+		t.Errorf("expected old identity key %x, got %x", oldIdentity, evt.OldIdentityKey)
+	}
+	if !bytes.Equal(evt.NewIdentityKey, newIdentity[:]) {
+		t.Errorf("expected new identity key %x, got %x", newIdentity, evt.NewIdentityKey)
+	}
+}
@@ -272,8 +272,12 @@ func (cli *Client) parseNewsletterMessages(node *waBinary.Node) []*types.Newslet
 		if child.Tag != "message" {
 			continue
 		}
+		ag := child.AttrGetter()
 		msg := types.NewsletterMessage{
-			MessageServerID: child.AttrGetter().Int("server_id"),
+			MessageServerID: ag.Int("server_id"),
+			MessageID:       ag.OptionalString("id"),
+			Type:            ag.OptionalString("type"),
+			Timestamp:       ag.OptionalUnixTime("t"),
 			ViewsCount:      0,
 			ReactionCounts:  nil,
 		}
@@ -372,12 +376,13 @@ func (cli *Client) handleStatusNotification(node *waBinary.Node) {
 }
 
 func (cli *Client) handleNotification(node *waBinary.Node) {
+	go cli.sendAck(node)
 	ag := node.AttrGetter()
 	notifType := ag.String("type")
 	if !ag.OK() {
+		cli.Log.Warnf("Got notification without type: %s", node.XMLString())
 		return
 	}
-	go cli.sendAck(node)
 	switch notifType {
 	case "encrypt":
 		go cli.handleEncryptNotification(node)
@@ -7,7 +7,9 @@
 package whatsmeow
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	waBinary "go.mau.fi/whatsmeow/binary"
 	"go.mau.fi/whatsmeow/types"
@@ -116,11 +118,22 @@ func (cli *Client) SubscribePresence(jid types.JID) error {
 // SendChatPresence updates the user's typing status in a specific chat.
 //
 // The media parameter can be set to indicate the user is recording media (like a voice message) rather than typing a text message.
+//
+// If Client.ChatPresenceRefreshInterval is set, the composing state is resent automatically at that interval
+// until another state (e.g. types.ChatPresencePaused) is sent to the same chat or the client disconnects.
 func (cli *Client) SendChatPresence(jid types.JID, state types.ChatPresence, media types.ChatPresenceMedia) error {
 	ownID := cli.getOwnID()
 	if ownID.IsEmpty() {
 		return ErrNotLoggedIn
 	}
+	err := cli.sendChatPresence(ownID, jid, state, media)
+	if err == nil || state != types.ChatPresenceComposing {
+		cli.updateChatPresenceRefresh(jid, state, media)
+	}
+	return err
+}
+
+func (cli *Client) sendChatPresence(ownID, jid types.JID, state types.ChatPresence, media types.ChatPresenceMedia) error {
 	content := []waBinary.Node{{Tag: string(state)}}
 	if state == types.ChatPresenceComposing && len(media) > 0 {
 		content[0].Attrs = waBinary.Attrs{
@@ -136,3 +149,59 @@ func (cli *Client) SendChatPresence(jid types.JID, state types.ChatPresence, med
 		Content: content,
 	})
 }
+
+type chatPresenceRefresh struct {
+	cancel context.CancelFunc
+}
+
+func (cli *Client) updateChatPresenceRefresh(jid types.JID, state types.ChatPresence, media types.ChatPresenceMedia) {
+	cli.chatPresenceRefreshLock.Lock()
+	defer cli.chatPresenceRefreshLock.Unlock()
+	if existing, ok := cli.chatPresenceRefresh[jid]; ok {
+		existing.cancel()
+		delete(cli.chatPresenceRefresh, jid)
+	}
+	if state != types.ChatPresenceComposing || cli.ChatPresenceRefreshInterval <= 0 {
+		return
+	}
+	var sockCtx context.Context
+	cli.socketLock.RLock()
+	if cli.socket != nil {
+		sockCtx = cli.socket.Context()
+	}
+	cli.socketLock.RUnlock()
+	if sockCtx == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(sockCtx)
+	refresh := &chatPresenceRefresh{cancel: cancel}
+	cli.chatPresenceRefresh[jid] = refresh
+	go cli.chatPresenceRefreshLoop(ctx, refresh, jid, media)
+}
+
+func (cli *Client) chatPresenceRefreshLoop(ctx context.Context, refresh *chatPresenceRefresh, jid types.JID, media types.ChatPresenceMedia) {
+	ticker := time.NewTicker(cli.ChatPresenceRefreshInterval)
+	defer func() {
+		ticker.Stop()
+		cli.chatPresenceRefreshLock.Lock()
+		if cli.chatPresenceRefresh[jid] == refresh {
+			delete(cli.chatPresenceRefresh, jid)
+		}
+		cli.chatPresenceRefreshLock.Unlock()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ownID := cli.getOwnID()
+			if ownID.IsEmpty() {
+				return
+			}
+			err := cli.sendChatPresence(ownID, jid, types.ChatPresenceComposing, media)
+			if err != nil {
+				cli.Log.Warnf("Failed to refresh chat presence in %s: %v", jid, err)
+			}
+		}
+	}
+}
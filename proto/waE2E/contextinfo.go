@@ -0,0 +1,34 @@
+package waE2E
+
+import (
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// FindContextInfo returns the ContextInfo of the content in the message, e.g. ExtendedTextMessage.ContextInfo
+// for text messages or ImageMessage.ContextInfo for images. Returns nil if the content type doesn't have a ContextInfo.
+//
+// If create is true and the content doesn't have a ContextInfo yet, an empty one is created and returned.
+// Otherwise, nil is returned if the ContextInfo isn't set. Note that Conversation messages can't have a ContextInfo:
+// they must be converted to ExtendedTextMessage first to set one.
+func (x *Message) FindContextInfo(create bool) *ContextInfo {
+	if x == nil {
+		return nil
+	}
+	var ctxInfo *ContextInfo
+	x.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, val protoreflect.Value) bool {
+		if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return true
+		}
+		subMsg := val.Message()
+		ctxField := subMsg.Descriptor().Fields().ByName("contextInfo")
+		if ctxField == nil {
+			return true
+		} else if create {
+			ctxInfo, _ = subMsg.Mutable(ctxField).Message().Interface().(*ContextInfo)
+		} else if subMsg.Has(ctxField) {
+			ctxInfo, _ = subMsg.Get(ctxField).Message().Interface().(*ContextInfo)
+		}
+		return ctxInfo == nil
+	})
+	return ctxInfo
+}
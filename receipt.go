@@ -128,7 +128,15 @@ func (cli *Client) sendAck(node *waBinary.Node) {
 // You can mark multiple messages as read at the same time, but only if the messages were sent by the same user.
 // To mark messages by different users as read, you must call MarkRead multiple times (once for each user).
 //
+// If Client.MarkReadDebounce is set, the receipt is queued and sent together with other MarkRead calls
+// for the same chat, sender and receipt type, and errors from sending are only logged.
+//
 // To mark a voice message as played, specify types.ReceiptTypePlayed as the last parameter.
+// To only sync the read status to your own other devices without notifying the sender,
+// specify types.ReceiptTypeReadSelf (or types.ReceiptTypePlayedSelf) instead.
+// Normal read receipts are automatically converted to read-self in newsletters and if read receipts
+// are disabled in the privacy settings. Played receipts are sent as-is, as voice messages are still
+// marked as played when read receipts are disabled.
 // Providing more than one receipt type will panic: the parameter is only a vararg for backwards compatibility.
 func (cli *Client) MarkRead(ids []types.MessageID, timestamp time.Time, chat, sender types.JID, receiptTypeExtra ...types.ReceiptType) error {
 	if len(ids) == 0 {
@@ -140,6 +148,67 @@ func (cli *Client) MarkRead(ids []types.MessageID, timestamp time.Time, chat, se
 	} else if len(receiptTypeExtra) > 1 {
 		panic(fmt.Errorf("too many receipt types specified"))
 	}
+	if cli.MarkReadDebounce > 0 {
+		cli.queueReadReceipt(ids, timestamp, chat, sender, receiptType)
+		return nil
+	}
+	return cli.sendReadReceipt(ids, timestamp, chat, sender, receiptType)
+}
+
+// MarkReadSelf marks the given messages as read only on your own other devices, without sending a read receipt
+// to the sender. This is a shortcut for MarkRead with types.ReceiptTypeReadSelf, see MarkRead for the parameters.
+//
+// This is useful for bridges that want to sync the read status of messages read on the other side of the bridge
+// without telling the sender that the message was read.
+func (cli *Client) MarkReadSelf(ids []types.MessageID, timestamp time.Time, chat, sender types.JID) error {
+	return cli.MarkRead(ids, timestamp, chat, sender, types.ReceiptTypeReadSelf)
+}
+
+type readReceiptKey struct {
+	Chat   types.JID
+	Sender types.JID
+	Type   types.ReceiptType
+}
+
+type pendingReadReceipt struct {
+	IDs       []types.MessageID
+	Timestamp time.Time
+}
+
+func (cli *Client) queueReadReceipt(ids []types.MessageID, timestamp time.Time, chat, sender types.JID, receiptType types.ReceiptType) {
+	key := readReceiptKey{Chat: chat, Sender: sender, Type: receiptType}
+	cli.pendingReadReceiptsLock.Lock()
+	defer cli.pendingReadReceiptsLock.Unlock()
+	pending, ok := cli.pendingReadReceipts[key]
+	if !ok {
+		pending = &pendingReadReceipt{Timestamp: timestamp}
+		cli.pendingReadReceipts[key] = pending
+		time.AfterFunc(cli.MarkReadDebounce, func() {
+			cli.flushReadReceipt(key)
+		})
+	} else if timestamp.After(pending.Timestamp) {
+		pending.Timestamp = timestamp
+	}
+	pending.IDs = append(pending.IDs, ids...)
+}
+
+func (cli *Client) flushReadReceipt(key readReceiptKey) {
+	cli.pendingReadReceiptsLock.Lock()
+	pending := cli.pendingReadReceipts[key]
+	delete(cli.pendingReadReceipts, key)
+	cli.pendingReadReceiptsLock.Unlock()
+	if pending == nil {
+		return
+	}
+	err := cli.sendReadReceipt(pending.IDs, pending.Timestamp, key.Chat, key.Sender, key.Type)
+	if err != nil {
+		cli.Log.Errorf("Failed to send debounced %s receipt for %d messages in %s: %v", key.Type, len(pending.IDs), key.Chat, err)
+	} else {
+		cli.Log.Debugf("Sent debounced %s receipt for %d messages in %s", key.Type, len(pending.IDs), key.Chat)
+	}
+}
+
+func (cli *Client) sendReadReceipt(ids []types.MessageID, timestamp time.Time, chat, sender types.JID, receiptType types.ReceiptType) error {
 	node := waBinary.Node{
 		Tag: "receipt",
 		Attrs: waBinary.Attrs{
@@ -159,16 +228,49 @@ func (cli *Client) MarkRead(ids []types.MessageID, timestamp time.Time, chat, se
 	if !sender.IsEmpty() && chat.Server != types.DefaultUserServer && chat.Server != types.MessengerServer {
 		node.Attrs["participant"] = sender.ToNonAD()
 	}
-	if len(ids) > 1 {
-		children := make([]waBinary.Node, len(ids)-1)
-		for i := 1; i < len(ids); i++ {
-			children[i-1].Tag = "item"
-			children[i-1].Attrs = waBinary.Attrs{"id": ids[i]}
-		}
-		node.Content = []waBinary.Node{{
-			Tag:     "list",
-			Content: children,
-		}}
+	node.Content = receiptIDList(ids)
+	return cli.sendNode(node)
+}
+
+// receiptIDList returns the list node for the message IDs after the first one in a receipt.
+func receiptIDList(ids []types.MessageID) []waBinary.Node {
+	if len(ids) <= 1 {
+		return nil
+	}
+	children := make([]waBinary.Node, len(ids)-1)
+	for i := 1; i < len(ids); i++ {
+		children[i-1].Tag = "item"
+		children[i-1].Attrs = waBinary.Attrs{"id": ids[i]}
+	}
+	return []waBinary.Node{{
+		Tag:     "list",
+		Content: children,
+	}}
+}
+
+// SendDeliveryReceipt sends a normal delivery receipt (two gray ticks) for the given message IDs.
+//
+// This is meant for clients that disable Client.AutomaticReceipts to decide themselves when messages are marked
+// as delivered. Unlike automatic receipts, this always sends an active delivery receipt regardless of the
+// presence state (see SetForceActiveDeliveryReceipts).
+//
+// The chat and sender parameters work the same way as in MarkRead: chat is the user ID in DMs and group ID
+// in group chats, and sender must be set in group chats. Multiple IDs can only be receipted together if they
+// were sent by the same user.
+func (cli *Client) SendDeliveryReceipt(ids []types.MessageID, chat, sender types.JID) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("no message IDs specified")
+	}
+	node := waBinary.Node{
+		Tag: "receipt",
+		Attrs: waBinary.Attrs{
+			"id": ids[0],
+			"to": chat,
+		},
+		Content: receiptIDList(ids),
+	}
+	if !sender.IsEmpty() && chat.Server != types.DefaultUserServer && chat.Server != types.MessengerServer {
+		node.Attrs["participant"] = sender
 	}
 	return cli.sendNode(node)
 }
@@ -197,6 +299,12 @@ func (cli *Client) SetForceActiveDeliveryReceipts(active bool) {
 }
 
 func (cli *Client) sendMessageReceipt(info *types.MessageInfo) {
+	if !cli.AutomaticReceipts {
+		return
+	} else if info.IsGroup && cli.hasLeftGroup(info.Chat) {
+		cli.Log.Debugf("Not sending receipt for %s in %s: we're no longer in the group", info.ID, info.Chat)
+		return
+	}
 	attrs := waBinary.Attrs{
 		"id": info.ID,
 	}
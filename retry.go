@@ -30,8 +30,11 @@ import (
 	"go.mau.fi/whatsmeow/types/events"
 )
 
-// Number of sent messages to cache in memory for handling retry receipts.
-const recentMessagesSize = 256
+// DefaultRecentMessagesSize is the default value of Client.RecentMessagesSize.
+const DefaultRecentMessagesSize = 256
+
+// DefaultMaxRetryReceipts is the default value of Client.MaxRetryReceipts.
+const DefaultMaxRetryReceipts = 4
 
 type recentMessageKey struct {
 	To types.JID
@@ -41,6 +44,8 @@ type recentMessageKey struct {
 type RecentMessage struct {
 	wa *waProto.Message
 	fb *waMsgApplication.MessageApplication
+
+	added time.Time
 }
 
 func (rm RecentMessage) IsEmpty() bool {
@@ -48,24 +53,56 @@ func (rm RecentMessage) IsEmpty() bool {
 }
 
 func (cli *Client) addRecentMessage(to types.JID, id types.MessageID, wa *waProto.Message, fb *waMsgApplication.MessageApplication) {
+	if cli.RecentMessagesSize <= 0 {
+		return
+	}
 	cli.recentMessagesLock.Lock()
+	defer cli.recentMessagesLock.Unlock()
+	if len(cli.recentMessagesList) != cli.RecentMessagesSize {
+		// The size was changed (or this is the first message), so start with a fresh cache
+		cli.recentMessagesList = make([]recentMessageKey, cli.RecentMessagesSize)
+		cli.recentMessagesMap = make(map[recentMessageKey]RecentMessage, cli.RecentMessagesSize)
+		cli.recentMessagesPtr = 0
+	}
+	now := time.Now()
+	if cli.RecentMessagesMaxAge > 0 {
+		cli.evictOldRecentMessages(now.Add(-cli.RecentMessagesMaxAge))
+	}
 	key := recentMessageKey{to, id}
 	if cli.recentMessagesList[cli.recentMessagesPtr].ID != "" {
 		delete(cli.recentMessagesMap, cli.recentMessagesList[cli.recentMessagesPtr])
 	}
-	cli.recentMessagesMap[key] = RecentMessage{wa: wa, fb: fb}
+	cli.recentMessagesMap[key] = RecentMessage{wa: wa, fb: fb, added: now}
 	cli.recentMessagesList[cli.recentMessagesPtr] = key
 	cli.recentMessagesPtr++
 	if cli.recentMessagesPtr >= len(cli.recentMessagesList) {
 		cli.recentMessagesPtr = 0
 	}
-	cli.recentMessagesLock.Unlock()
+}
+
+// evictOldRecentMessages removes messages added before the given time from the recent message cache.
+// The list is a ring buffer in insertion order, so the oldest entries are found starting from the write pointer.
+func (cli *Client) evictOldRecentMessages(before time.Time) {
+	for i := 0; i < len(cli.recentMessagesList); i++ {
+		idx := (cli.recentMessagesPtr + i) % len(cli.recentMessagesList)
+		key := cli.recentMessagesList[idx]
+		if key.ID == "" {
+			continue
+		} else if !cli.recentMessagesMap[key].added.Before(before) {
+			break
+		}
+		delete(cli.recentMessagesMap, key)
+		cli.recentMessagesList[idx] = recentMessageKey{}
+	}
 }
 
 func (cli *Client) getRecentMessage(to types.JID, id types.MessageID) RecentMessage {
 	cli.recentMessagesLock.RLock()
 	msg, _ := cli.recentMessagesMap[recentMessageKey{to, id}]
 	cli.recentMessagesLock.RUnlock()
+	if cli.RecentMessagesMaxAge > 0 && time.Since(msg.added) > cli.RecentMessagesMaxAge {
+		return RecentMessage{}
+	}
 	return msg
 }
 
@@ -122,6 +159,10 @@ func (cli *Client) handleRetryReceipt(receipt *events.Receipt, node *waBinary.No
 	if !ag.OK() {
 		return ag.Error()
 	}
+	if receipt.IsGroup && cli.hasLeftGroup(receipt.Chat) {
+		cli.Log.Debugf("Ignoring retry receipt for %s in %s: we're no longer in the group", messageID, receipt.Chat)
+		return nil
+	}
 	msg, err := cli.getMessageForRetry(receipt, messageID)
 	if err != nil {
 		return err
@@ -312,7 +353,7 @@ func (cli *Client) cancelDelayedRequestFromPhone(msgID types.MessageID) {
 }
 
 // RequestFromPhoneDelay specifies how long to wait for the sender to resend the message before requesting from your phone.
-// This is only used if Client.AutomaticMessageRerequestFromPhone is true.
+// This is only used if Client.AutomaticMessageRerequestFromPhone is true. The value is shared by all clients.
 var RequestFromPhoneDelay = 5 * time.Second
 
 func (cli *Client) delayedRequestMessageFromPhone(info *types.MessageInfo) {
@@ -354,16 +395,65 @@ func (cli *Client) delayedRequestMessageFromPhone(info *types.MessageInfo) {
 	}
 }
 
+const (
+	retryReceiptBaseDelay = 1 * time.Second
+	retryReceiptMaxDelay  = 1 * time.Minute
+)
+
+// retryReceiptBackoff returns how long to wait before sending the given retry receipt.
+// The first retry receipt is sent immediately, after that the delay doubles for each retry.
+func retryReceiptBackoff(retryCount int) time.Duration {
+	if retryCount <= 1 {
+		return 0
+	} else if retryCount > 8 {
+		return retryReceiptMaxDelay
+	}
+	return min(retryReceiptBaseDelay<<(retryCount-2), retryReceiptMaxDelay)
+}
+
+func (cli *Client) maxRetryReceipts() int {
+	if cli.MaxRetryReceipts <= 0 {
+		return DefaultMaxRetryReceipts
+	}
+	return cli.MaxRetryReceipts
+}
+
+// PendingRetries returns a snapshot of the retry receipt counts of incoming messages, keyed by message ID.
+//
+// Messages are added when they fail to decrypt. The counts are only kept in memory and are not removed
+// when a retry succeeds, so this contains all messages that have needed retries since the client was created.
+func (cli *Client) PendingRetries() map[string]int {
+	cli.messageRetriesLock.Lock()
+	defer cli.messageRetriesLock.Unlock()
+	retries := make(map[string]int, len(cli.messageRetries))
+	for id, count := range cli.messageRetries {
+		retries[id] = count
+	}
+	return retries
+}
+
 // sendRetryReceipt sends a retry receipt for an incoming message.
 func (cli *Client) sendRetryReceipt(node *waBinary.Node, info *types.MessageInfo, forceIncludeIdentity bool) {
 	id, _ := node.Attrs["id"].(string)
+	if info.IsGroup && cli.hasLeftGroup(info.Chat) {
+		cli.Log.Debugf("Not sending retry receipt for %s in %s: we're no longer in the group", id, info.Chat)
+		return
+	}
 	children := node.GetChildren()
 	var retryCountInMsg int
+	var encType string
 	if len(children) == 1 && children[0].Tag == "enc" {
 		retryCountInMsg = children[0].AttrGetter().OptionalInt("count")
 	}
+	for _, child := range children {
+		if child.Tag == "enc" {
+			encType = child.AttrGetter().OptionalString("type")
+			break
+		}
+	}
 
 	cli.messageRetriesLock.Lock()
+	prevRetryCount := cli.messageRetries[id]
 	cli.messageRetries[id]++
 	retryCount := cli.messageRetries[id]
 	// In case the message is a retry response, and we restarted in between, find the count from the message
@@ -372,12 +462,22 @@ func (cli *Client) sendRetryReceipt(node *waBinary.Node, info *types.MessageInfo
 		cli.messageRetries[id] = retryCount
 	}
 	cli.messageRetriesLock.Unlock()
-	if retryCount >= 5 {
+	if cli.RetryReceiptHook != nil {
+		cli.RetryReceiptHook(info, retryCount)
+	}
+	if maxRetries := cli.maxRetryReceipts(); retryCount > maxRetries {
 		cli.Log.Warnf("Not sending any more retry receipts for %s", id)
+		if prevRetryCount <= maxRetries {
+			cli.dispatchEvent(&events.DecryptionFailure{Info: *info, EncType: encType, RetryCount: retryCount - 1})
+		}
 		return
 	}
 	if retryCount == 1 {
 		go cli.delayedRequestMessageFromPhone(info)
+	} else {
+		delay := retryReceiptBackoff(retryCount)
+		cli.Log.Debugf("Waiting %s before sending retry receipt #%d for %s", delay, retryCount, id)
+		time.Sleep(delay)
 	}
 
 	var registrationIDBytes [4]byte
@@ -0,0 +1,52 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryReceiptBackoff(t *testing.T) {
+	testCases := []struct {
+		retryCount int
+		expected   time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{2, 1 * time.Second},
+		{3, 2 * time.Second},
+		{4, 4 * time.Second},
+		{7, 32 * time.Second},
+		{8, 1 * time.Minute},
+		{9, 1 * time.Minute},
+		{100, 1 * time.Minute},
+	}
+	for _, tc := range testCases {
+		if actual := retryReceiptBackoff(tc.retryCount); actual != tc.expected {
+			t.Errorf("retryReceiptBackoff(%d) = %s, expected %s", tc.retryCount, actual, tc.expected)
+		}
+	}
+}
+
+func TestMaxRetryReceiptsDefault(t *testing.T) {
+	testCases := []struct {
+		configured int
+		expected   int
+	}{
+		{-1, DefaultMaxRetryReceipts},
+		{0, DefaultMaxRetryReceipts},
+		{1, 1},
+		{10, 10},
+	}
+	for _, tc := range testCases {
+		cli := &Client{MaxRetryReceipts: tc.configured}
+		if actual := cli.maxRetryReceipts(); actual != tc.expected {
+			t.Errorf("maxRetryReceipts() with MaxRetryReceipts=%d = %d, expected %d", tc.configured, actual, tc.expected)
+		}
+	}
+}
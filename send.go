@@ -17,6 +17,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -142,11 +143,43 @@ type SendRequestExtra struct {
 	MediaHandle string
 }
 
+type chatSendLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockChatSend locks the send lock of the given chat and returns a function that unlocks it.
+// The lock is removed from the map once nobody is using it.
+func (cli *Client) lockChatSend(chat types.JID) func() {
+	cli.chatSendLocksLock.Lock()
+	lock, ok := cli.chatSendLocks[chat]
+	if !ok {
+		lock = &chatSendLock{}
+		cli.chatSendLocks[chat] = lock
+	}
+	lock.refs++
+	cli.chatSendLocksLock.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		cli.chatSendLocksLock.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(cli.chatSendLocks, chat)
+		}
+		cli.chatSendLocksLock.Unlock()
+	}
+}
+
 // SendMessage sends the given message.
 //
 // This method will wait for the server to acknowledge the message before returning.
 // The return value is the timestamp of the message from the server.
 //
+// Concurrent calls for the same chat are sent one at a time, while messages to different chats
+// can wait for the server response in parallel.
+//
 // Optional parameters like the message ID can be specified with the SendRequestExtra struct.
 // Only one extra parameter is allowed, put all necessary parameters in the same struct.
 //
@@ -271,10 +304,12 @@ func (cli *Client) SendMessage(ctx context.Context, to types.JID, message *waE2E
 	}
 
 	start := time.Now()
-	// Sending multiple messages at a time can cause weird issues and makes it harder to retry safely
+	// Messages to the same chat are sent one at a time to keep them in order and to make retrying safe.
+	unlockChat := cli.lockChatSend(to)
+	defer unlockChat()
+	// Encryption state is shared between chats, so only one message can be encrypted at a time.
 	cli.messageSendLock.Lock()
 	resp.DebugTimings.Queue = time.Since(start)
-	defer cli.messageSendLock.Unlock()
 
 	respChan := cli.waitResponse(req.ID)
 	// Peer message retries aren't implemented yet
@@ -292,20 +327,23 @@ func (cli *Client) SendMessage(ctx context.Context, to types.JID, message *waE2E
 	}
 	var phash string
 	var data []byte
-	switch to.Server {
-	case types.GroupServer, types.BroadcastServer:
-		phash, data, err = cli.sendGroup(ctx, to, ownID, req.ID, message, &resp.DebugTimings, botNode)
-	case types.DefaultUserServer:
-		if req.Peer {
-			data, err = cli.sendPeerMessage(to, req.ID, message, &resp.DebugTimings)
-		} else {
-			data, err = cli.sendDM(ctx, to, ownID, req.ID, message, &resp.DebugTimings, botNode)
+	func() {
+		defer cli.messageSendLock.Unlock()
+		switch to.Server {
+		case types.GroupServer, types.BroadcastServer:
+			phash, data, err = cli.sendGroup(ctx, to, ownID, req.ID, message, &resp.DebugTimings, botNode)
+		case types.DefaultUserServer:
+			if req.Peer {
+				data, err = cli.sendPeerMessage(to, req.ID, message, &resp.DebugTimings)
+			} else {
+				data, err = cli.sendDM(ctx, to, ownID, req.ID, message, &resp.DebugTimings, botNode)
+			}
+		case types.NewsletterServer:
+			data, err = cli.sendNewsletter(to, req.ID, message, req.MediaHandle, &resp.DebugTimings)
+		default:
+			err = fmt.Errorf("%w %s", ErrUnknownServer, to.Server)
 		}
-	case types.NewsletterServer:
-		data, err = cli.sendNewsletter(to, req.ID, message, req.MediaHandle, &resp.DebugTimings)
-	default:
-		err = fmt.Errorf("%w %s", ErrUnknownServer, to.Server)
-	}
+	}()
 	start = time.Now()
 	if err != nil {
 		cli.cancelResponse(req.ID, respChan)
@@ -347,10 +385,7 @@ func (cli *Client) SendMessage(ctx context.Context, to types.JID, message *waE2E
 	expectedPHash := ag.OptionalString("phash")
 	if len(expectedPHash) > 0 && phash != expectedPHash {
 		cli.Log.Warnf("Server returned different participant list hash when sending to %s. Some devices may not have received the message.", to)
-		// TODO also invalidate device list caches
-		cli.groupParticipantsCacheLock.Lock()
-		delete(cli.groupParticipantsCache, to)
-		cli.groupParticipantsCacheLock.Unlock()
+		cli.invalidateParticipantCaches(to)
 	}
 	return
 }
@@ -490,6 +525,74 @@ func (cli *Client) BuildEdit(chat types.JID, id types.MessageID, newContent *waP
 	}
 }
 
+// SetReplyContext makes the given outgoing message a reply to the given message by filling the ContextInfo
+// of the message content. Plain text (Conversation) messages are converted to ExtendedTextMessage first,
+// as Conversation can't have a ContextInfo. Other fields of an existing ContextInfo (such as mentions) are kept.
+//
+//	msg := &waProto.Message{Conversation: proto.String("reply text")}
+//	err := cli.SetReplyContext(msg, &evt.Info, evt.Message)
+//	// handle error
+//	resp, err := cli.SendMessage(context.Background(), evt.Info.Chat, msg)
+func (cli *Client) SetReplyContext(outgoing *waProto.Message, quotedInfo *types.MessageInfo, quoted *waProto.Message) error {
+	ctxInfo := getMutableContextInfo(outgoing)
+	if ctxInfo == nil {
+		return ErrMessageHasNoContextInfo
+	}
+	ctxInfo.StanzaID = proto.String(quotedInfo.ID)
+	ctxInfo.Participant = proto.String(quotedInfo.Sender.ToNonAD().String())
+	ctxInfo.QuotedMessage = quoted
+	return nil
+}
+
+// getMutableContextInfo finds the ContextInfo of the content in the given message, creating it if necessary.
+// Conversation messages are converted to ExtendedTextMessage, as Conversation can't have a ContextInfo.
+// If the message content doesn't support context info, this returns nil.
+func getMutableContextInfo(msg *waProto.Message) *waProto.ContextInfo {
+	if msg.Conversation != nil {
+		msg.ExtendedTextMessage = &waProto.ExtendedTextMessage{Text: msg.Conversation}
+		msg.Conversation = nil
+	}
+	return msg.FindContextInfo(true)
+}
+
+// FrequentlyForwardedThreshold is the forwarding score at which WhatsApp clients show a message as
+// "Forwarded many times". Official clients only allow forwarding such messages to one chat at a time.
+const FrequentlyForwardedThreshold = 5
+
+// BuildForward builds a message that forwards the content of the given message.
+//
+// The content is copied (media is not reuploaded, the forward points at the same file), the original ContextInfo
+// (replies, mentions, etc.) is removed, and the forwarded flag is set with the forwarding score incremented by one.
+// The given message must already be unwrapped, e.g. the Message field of *events.Message.
+func (cli *Client) BuildForward(original *waProto.Message) (*waProto.Message, error) {
+	msg := proto.Clone(original).(*waProto.Message)
+	msg.MessageContextInfo = nil
+	ctxInfo := getMutableContextInfo(msg)
+	if ctxInfo == nil {
+		return nil, ErrMessageHasNoContextInfo
+	}
+	score := ctxInfo.GetForwardingScore()
+	if score == 0 && ctxInfo.GetIsForwarded() {
+		score = 1
+	}
+	proto.Reset(ctxInfo)
+	ctxInfo.IsForwarded = proto.Bool(true)
+	ctxInfo.ForwardingScore = proto.Uint32(score + 1)
+	return msg, nil
+}
+
+// ForwardMessage forwards the content of the given message to the given chat.
+//
+// This is a shortcut for BuildForward and SendMessage. Use BuildForward directly to inspect the forwarding score,
+// e.g. to refuse forwarding messages at or above FrequentlyForwardedThreshold to multiple chats.
+func (cli *Client) ForwardMessage(ctx context.Context, to types.JID, original *waProto.Message, extra ...SendRequestExtra) (SendResponse, error) {
+	msg, err := cli.BuildForward(original)
+	if err != nil {
+		return SendResponse{}, err
+	}
+	return cli.SendMessage(ctx, to, msg, extra...)
+}
+
 const (
 	DisappearingTimerOff     = time.Duration(0)
 	DisappearingTimer24Hours = 24 * time.Hour
@@ -1095,7 +1198,7 @@ func (cli *Client) encryptMessageForDevice(plaintext []byte, to types.JID, bundl
 		err := builder.ProcessBundle(bundle)
 		if cli.AutoTrustIdentity && errors.Is(err, signalerror.ErrUntrustedIdentity) {
 			cli.Log.Warnf("Got %v error while trying to process prekey bundle for %s, clearing stored identity and retrying", err, to)
-			cli.clearUntrustedIdentity(to)
+			cli.clearUntrustedIdentity(to, bundle.IdentityKey().PublicKey().PublicKey())
 			err = builder.ProcessBundle(bundle)
 		}
 		if err != nil {
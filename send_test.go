@@ -0,0 +1,66 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestLockChatSend(t *testing.T) {
+	cli := &Client{chatSendLocks: make(map[types.JID]*chatSendLock)}
+	chatA := types.NewJID("1111", types.DefaultUserServer)
+	chatB := types.NewJID("2222", types.DefaultUserServer)
+
+	var active [2]atomic.Int32
+	var maxActive [2]atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		idx := i % 2
+		chat := chatA
+		if idx == 1 {
+			chat = chatB
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := cli.lockChatSend(chat)
+			defer unlock()
+			n := active[idx].Add(1)
+			if n > maxActive[idx].Load() {
+				maxActive[idx].Store(n)
+			}
+			time.Sleep(time.Millisecond)
+			active[idx].Add(-1)
+		}()
+	}
+	wg.Wait()
+	if maxActive[0].Load() != 1 || maxActive[1].Load() != 1 {
+		t.Errorf("sends to the same chat overlapped: max %d and %d", maxActive[0].Load(), maxActive[1].Load())
+	}
+	if len(cli.chatSendLocks) != 0 {
+		t.Errorf("expected chat send locks to be cleaned up, %d left", len(cli.chatSendLocks))
+	}
+
+	// Sends to different chats must not block each other.
+	unlockA := cli.lockChatSend(chatA)
+	done := make(chan struct{})
+	go func() {
+		cli.lockChatSend(chatB)()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("send to other chat was blocked")
+	}
+	unlockA()
+}
@@ -131,10 +131,12 @@ func (cli *Client) SendFBMessage(
 	resp.ID = req.ID
 
 	start := time.Now()
-	// Sending multiple messages at a time can cause weird issues and makes it harder to retry safely
+	// Messages to the same chat are sent one at a time to keep them in order and to make retrying safe.
+	unlockChat := cli.lockChatSend(to)
+	defer unlockChat()
+	// Encryption state is shared between chats, so only one message can be encrypted at a time.
 	cli.messageSendLock.Lock()
 	resp.DebugTimings.Queue = time.Since(start)
-	defer cli.messageSendLock.Unlock()
 
 	respChan := cli.waitResponse(req.ID)
 	if !req.Peer {
@@ -142,19 +144,22 @@ func (cli *Client) SendFBMessage(
 	}
 	var phash string
 	var data []byte
-	switch to.Server {
-	case types.GroupServer:
-		phash, data, err = cli.sendGroupV3(ctx, to, ownID, req.ID, messageApp, msgAttrs, frankingTag, &resp.DebugTimings)
-	case types.DefaultUserServer, types.MessengerServer:
-		if req.Peer {
-			err = fmt.Errorf("peer messages to fb are not yet supported")
-			//data, err = cli.sendPeerMessage(to, req.ID, message, &resp.DebugTimings)
-		} else {
-			data, phash, err = cli.sendDMV3(ctx, to, ownID, req.ID, messageApp, msgAttrs, frankingTag, &resp.DebugTimings)
+	func() {
+		defer cli.messageSendLock.Unlock()
+		switch to.Server {
+		case types.GroupServer:
+			phash, data, err = cli.sendGroupV3(ctx, to, ownID, req.ID, messageApp, msgAttrs, frankingTag, &resp.DebugTimings)
+		case types.DefaultUserServer, types.MessengerServer:
+			if req.Peer {
+				err = fmt.Errorf("peer messages to fb are not yet supported")
+				//data, err = cli.sendPeerMessage(to, req.ID, message, &resp.DebugTimings)
+			} else {
+				data, phash, err = cli.sendDMV3(ctx, to, ownID, req.ID, messageApp, msgAttrs, frankingTag, &resp.DebugTimings)
+			}
+		default:
+			err = fmt.Errorf("%w %s", ErrUnknownServer, to.Server)
 		}
-	default:
-		err = fmt.Errorf("%w %s", ErrUnknownServer, to.Server)
-	}
+	}()
 	start = time.Now()
 	if err != nil {
 		cli.cancelResponse(req.ID, respChan)
@@ -196,10 +201,7 @@ func (cli *Client) SendFBMessage(
 	expectedPHash := ag.OptionalString("phash")
 	if len(expectedPHash) > 0 && phash != expectedPHash {
 		cli.Log.Warnf("Server returned different participant list hash when sending to %s. Some devices may not have received the message.", to)
-		// TODO also invalidate device list caches
-		cli.groupParticipantsCacheLock.Lock()
-		delete(cli.groupParticipantsCache, to)
-		cli.groupParticipantsCacheLock.Unlock()
+		cli.invalidateParticipantCaches(to)
 	}
 	return
 }
@@ -596,7 +598,7 @@ func (cli *Client) encryptMessageForDeviceV3(
 		err := builder.ProcessBundle(bundle)
 		if cli.AutoTrustIdentity && errors.Is(err, signalerror.ErrUntrustedIdentity) {
 			cli.Log.Warnf("Got %v error while trying to process prekey bundle for %s, clearing stored identity and retrying", err, to)
-			cli.clearUntrustedIdentity(to)
+			cli.clearUntrustedIdentity(to, bundle.IdentityKey().PublicKey().PublicKey())
 			err = builder.ProcessBundle(bundle)
 		}
 		if err != nil {
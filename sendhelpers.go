@@ -0,0 +1,85 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// SendLocation sends a static location pin to the given chat. The name is optional.
+func (cli *Client) SendLocation(ctx context.Context, to types.JID, latitude, longitude float64, name string, extra ...SendRequestExtra) (SendResponse, error) {
+	if err := validateLocation(latitude, longitude); err != nil {
+		return SendResponse{}, err
+	}
+	return cli.SendMessage(ctx, to, &waE2E.Message{LocationMessage: &waE2E.LocationMessage{
+		DegreesLatitude:  proto.Float64(latitude),
+		DegreesLongitude: proto.Float64(longitude),
+		Name:             optionalString(name),
+	}}, extra...)
+}
+
+func validateLocation(latitude, longitude float64) error {
+	if math.IsNaN(latitude) || latitude < -90 || latitude > 90 {
+		return fmt.Errorf("%w: latitude %f is not between -90 and 90", ErrInvalidLocation, latitude)
+	} else if math.IsNaN(longitude) || longitude < -180 || longitude > 180 {
+		return fmt.Errorf("%w: longitude %f is not between -180 and 180", ErrInvalidLocation, longitude)
+	}
+	return nil
+}
+
+// SendContact sends a contact card to the given chat.
+//
+// The vCard must contain a FN (formatted name) property, which is used as the display name of the message.
+// To let recipients message the contact directly, include a TEL property with a waid parameter, e.g.
+//
+//	TEL;type=CELL;waid=15551234567:+1 555 123 4567
+func (cli *Client) SendContact(ctx context.Context, to types.JID, vcard string, extra ...SendRequestExtra) (SendResponse, error) {
+	displayName, err := getVCardDisplayName(vcard)
+	if err != nil {
+		return SendResponse{}, err
+	}
+	return cli.SendMessage(ctx, to, &waE2E.Message{ContactMessage: &waE2E.ContactMessage{
+		DisplayName: proto.String(displayName),
+		Vcard:       proto.String(vcard),
+	}}, extra...)
+}
+
+var vCardValueUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func getVCardDisplayName(vcard string) (string, error) {
+	lines := strings.Split(strings.ReplaceAll(vcard, "\r\n", "\n"), "\n")
+	if len(lines) == 0 || !strings.EqualFold(strings.TrimSpace(lines[0]), "BEGIN:VCARD") {
+		return "", fmt.Errorf("%w: must start with BEGIN:VCARD", ErrInvalidVCard)
+	}
+	for i := 1; i < len(lines); i++ {
+		line := lines[i]
+		// Lines starting with a space or tab are continuations of the previous line
+		for i+1 < len(lines) && len(lines[i+1]) > 0 && (lines[i+1][0] == ' ' || lines[i+1][0] == '\t') {
+			i++
+			line += lines[i][1:]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		// Strip parameters like FN;CHARSET=UTF-8
+		key, _, _ = strings.Cut(key, ";")
+		value = strings.TrimSpace(vCardValueUnescaper.Replace(value))
+		if strings.EqualFold(key, "FN") && value != "" {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("%w: missing FN property", ErrInvalidVCard)
+}
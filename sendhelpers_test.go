@@ -0,0 +1,93 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestValidateLocation(t *testing.T) {
+	testCases := []struct {
+		name                string
+		latitude, longitude float64
+		valid               bool
+	}{
+		{"origin", 0, 0, true},
+		{"helsinki", 60.1699, 24.9384, true},
+		{"latitude bounds", -90, 90, true},
+		{"longitude bounds", 0, -180, true},
+		{"longitude upper bound", 0, 180, true},
+		{"latitude too small", -90.0001, 0, false},
+		{"latitude too large", 90.0001, 0, false},
+		{"longitude too small", 0, -180.0001, false},
+		{"longitude too large", 0, 180.0001, false},
+		{"swapped coordinates", 120, 45, false},
+		{"NaN latitude", math.NaN(), 0, false},
+		{"NaN longitude", 0, math.NaN(), false},
+		{"infinite longitude", 0, math.Inf(1), false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateLocation(tc.latitude, tc.longitude)
+			if tc.valid && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			} else if !tc.valid && !errors.Is(err, ErrInvalidLocation) {
+				t.Errorf("expected %v, got %v", ErrInvalidLocation, err)
+			}
+		})
+	}
+}
+
+func TestGetVCardDisplayName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		vcard    string
+		expected string
+	}{
+		{"simple", "BEGIN:VCARD\nVERSION:3.0\nFN:John Doe\nEND:VCARD", "John Doe"},
+		{"CRLF line endings", "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:John Doe\r\nEND:VCARD\r\n", "John Doe"},
+		{"lowercase and parameters", "begin:vcard\nfn;CHARSET=UTF-8:Jöhn Döe\nend:vcard", "Jöhn Döe"},
+		{"folded line", "BEGIN:VCARD\nFN:John\n  Doe\nEND:VCARD", "John Doe"},
+		{"folded line with tab", "BEGIN:VCARD\nFN:Jo\n\thn Doe\nEND:VCARD", "John Doe"},
+		{"escaped characters", "BEGIN:VCARD\nFN:Doe\\, John \\; Jr.\\\\\nEND:VCARD", "Doe, John ; Jr.\\"},
+		{"colon in value", "BEGIN:VCARD\nFN:Dr: John\nEND:VCARD", "Dr: John"},
+		{"empty FN is skipped", "BEGIN:VCARD\nFN: \nFN:John Doe\nEND:VCARD", "John Doe"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := getVCardDisplayName(tc.vcard)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if actual != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGetVCardDisplayNameInvalid(t *testing.T) {
+	testCases := []struct {
+		name  string
+		vcard string
+	}{
+		{"empty", ""},
+		{"no BEGIN", "FN:John Doe\nEND:VCARD"},
+		{"no FN", "BEGIN:VCARD\nVERSION:3.0\nN:Doe;John;;;\nEND:VCARD"},
+		{"only empty FN", "BEGIN:VCARD\nFN:\nEND:VCARD"},
+		{"FN as parameter of another property", "BEGIN:VCARD\nN;FN=x:Doe;John\nEND:VCARD"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := getVCardDisplayName(tc.vcard)
+			if !errors.Is(err, ErrInvalidVCard) {
+				t.Errorf("expected %v, got %v", ErrInvalidVCard, err)
+			}
+		})
+	}
+}
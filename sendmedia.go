@@ -0,0 +1,198 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// SendMediaOptions contains optional parameters for SendImage, SendVideo, SendAudio and SendDocument.
+type SendMediaOptions struct {
+	// The caption to show under the media. Not used for audio.
+	Caption string
+	// The mime type of the file. If empty, it's detected from the file contents.
+	MimeType string
+	// The file name. Only used for documents.
+	FileName string
+	// A JPEG thumbnail to embed in the message. Not used for audio.
+	// If empty, a thumbnail is generated automatically for images and for videos with VideoFrame set.
+	Thumbnail []byte
+	// A frame of the video to generate a thumbnail from. Only used for videos when Thumbnail is empty.
+	VideoFrame image.Image
+	// The dimensions of the image or video. If not set, these are detected automatically for images,
+	// and taken from VideoFrame for videos.
+	Width, Height uint32
+	// The duration of the video or audio in seconds.
+	Seconds uint32
+	// Should audio be sent as a voice message?
+	PTT bool
+	// Optional context info, e.g. for replies and mentions.
+	ContextInfo *waE2E.ContextInfo
+}
+
+func (opts *SendMediaOptions) getMimeType(data []byte) string {
+	if opts.MimeType != "" {
+		return opts.MimeType
+	}
+	return http.DetectContentType(data)
+}
+
+func optionalString(val string) *string {
+	if val == "" {
+		return nil
+	}
+	return &val
+}
+
+func optionalUint32(val uint32) *uint32 {
+	if val == 0 {
+		return nil
+	}
+	return &val
+}
+
+// SendImage uploads the given image and sends it to the given chat.
+//
+// This is a shortcut for calling Upload and SendMessage with an ImageMessage. JPEG, PNG and GIF images
+// are decoded to detect the dimensions and generate a thumbnail, unless a thumbnail is provided.
+// To set fields that aren't in SendMediaOptions, use those functions directly.
+func (cli *Client) SendImage(ctx context.Context, to types.JID, data []byte, opts SendMediaOptions, extra ...SendRequestExtra) (SendResponse, error) {
+	uploaded, err := cli.Upload(ctx, data, MediaImage)
+	if err != nil {
+		return SendResponse{}, fmt.Errorf("failed to upload image: %w", err)
+	}
+	if opts.Thumbnail == nil {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			cli.Log.Warnf("Failed to decode image to generate thumbnail: %v", err)
+		} else {
+			if opts.Width == 0 || opts.Height == 0 {
+				opts.Width, opts.Height = uint32(img.Bounds().Dx()), uint32(img.Bounds().Dy())
+			}
+			opts.Thumbnail, err = GenerateThumbnail(img)
+			if err != nil {
+				cli.Log.Warnf("Failed to generate image thumbnail: %v", err)
+			}
+		}
+	} else if opts.Width == 0 || opts.Height == 0 {
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			cli.Log.Debugf("Failed to detect image dimensions: %v", err)
+		} else {
+			opts.Width, opts.Height = uint32(cfg.Width), uint32(cfg.Height)
+		}
+	}
+	return cli.SendMessage(ctx, to, &waE2E.Message{ImageMessage: &waE2E.ImageMessage{
+		Caption:       optionalString(opts.Caption),
+		Mimetype:      proto.String(opts.getMimeType(data)),
+		JPEGThumbnail: opts.Thumbnail,
+		Width:         optionalUint32(opts.Width),
+		Height:        optionalUint32(opts.Height),
+		ContextInfo:   opts.ContextInfo,
+
+		URL:           &uploaded.URL,
+		DirectPath:    &uploaded.DirectPath,
+		MediaKey:      uploaded.MediaKey,
+		FileEncSHA256: uploaded.FileEncSHA256,
+		FileSHA256:    uploaded.FileSHA256,
+		FileLength:    &uploaded.FileLength,
+	}}, extra...)
+}
+
+// SendVideo uploads the given video and sends it to the given chat.
+//
+// The duration can't be detected automatically and should be set in the options. The dimensions are taken
+// from VideoFrame if it's set.
+func (cli *Client) SendVideo(ctx context.Context, to types.JID, data []byte, opts SendMediaOptions, extra ...SendRequestExtra) (SendResponse, error) {
+	uploaded, err := cli.Upload(ctx, data, MediaVideo)
+	if err != nil {
+		return SendResponse{}, fmt.Errorf("failed to upload video: %w", err)
+	}
+	if opts.Thumbnail == nil && opts.VideoFrame != nil {
+		if opts.Width == 0 || opts.Height == 0 {
+			opts.Width, opts.Height = uint32(opts.VideoFrame.Bounds().Dx()), uint32(opts.VideoFrame.Bounds().Dy())
+		}
+		opts.Thumbnail, err = GenerateThumbnail(opts.VideoFrame)
+		if err != nil {
+			cli.Log.Warnf("Failed to generate video thumbnail: %v", err)
+		}
+	}
+	return cli.SendMessage(ctx, to, &waE2E.Message{VideoMessage: &waE2E.VideoMessage{
+		Caption:       optionalString(opts.Caption),
+		Mimetype:      proto.String(opts.getMimeType(data)),
+		JPEGThumbnail: opts.Thumbnail,
+		Width:         optionalUint32(opts.Width),
+		Height:        optionalUint32(opts.Height),
+		Seconds:       optionalUint32(opts.Seconds),
+		ContextInfo:   opts.ContextInfo,
+
+		URL:           &uploaded.URL,
+		DirectPath:    &uploaded.DirectPath,
+		MediaKey:      uploaded.MediaKey,
+		FileEncSHA256: uploaded.FileEncSHA256,
+		FileSHA256:    uploaded.FileSHA256,
+		FileLength:    &uploaded.FileLength,
+	}}, extra...)
+}
+
+// SendAudio uploads the given audio file and sends it to the given chat.
+//
+// Voice messages (PTT) should be Opus in an Ogg container with the mime type "audio/ogg; codecs=opus".
+func (cli *Client) SendAudio(ctx context.Context, to types.JID, data []byte, opts SendMediaOptions, extra ...SendRequestExtra) (SendResponse, error) {
+	uploaded, err := cli.Upload(ctx, data, MediaAudio)
+	if err != nil {
+		return SendResponse{}, fmt.Errorf("failed to upload audio: %w", err)
+	}
+	return cli.SendMessage(ctx, to, &waE2E.Message{AudioMessage: &waE2E.AudioMessage{
+		Mimetype:    proto.String(opts.getMimeType(data)),
+		Seconds:     optionalUint32(opts.Seconds),
+		PTT:         proto.Bool(opts.PTT),
+		ContextInfo: opts.ContextInfo,
+
+		URL:           &uploaded.URL,
+		DirectPath:    &uploaded.DirectPath,
+		MediaKey:      uploaded.MediaKey,
+		FileEncSHA256: uploaded.FileEncSHA256,
+		FileSHA256:    uploaded.FileSHA256,
+		FileLength:    &uploaded.FileLength,
+	}}, extra...)
+}
+
+// SendDocument uploads the given file and sends it to the given chat as a document.
+func (cli *Client) SendDocument(ctx context.Context, to types.JID, data []byte, opts SendMediaOptions, extra ...SendRequestExtra) (SendResponse, error) {
+	uploaded, err := cli.Upload(ctx, data, MediaDocument)
+	if err != nil {
+		return SendResponse{}, fmt.Errorf("failed to upload document: %w", err)
+	}
+	return cli.SendMessage(ctx, to, &waE2E.Message{DocumentMessage: &waE2E.DocumentMessage{
+		Caption:       optionalString(opts.Caption),
+		Mimetype:      proto.String(opts.getMimeType(data)),
+		FileName:      optionalString(opts.FileName),
+		Title:         optionalString(opts.FileName),
+		JPEGThumbnail: opts.Thumbnail,
+		ContextInfo:   opts.ContextInfo,
+
+		URL:           &uploaded.URL,
+		DirectPath:    &uploaded.DirectPath,
+		MediaKey:      uploaded.MediaKey,
+		FileEncSHA256: uploaded.FileEncSHA256,
+		FileSHA256:    uploaded.FileSHA256,
+		FileLength:    &uploaded.FileLength,
+	}}, extra...)
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// HasSession checks whether a Signal session is stored for the given device.
+// The JID must include the device part (e.g. the Sender of a message event).
+func (cli *Client) HasSession(jid types.JID) (bool, error) {
+	if cli == nil {
+		return false, ErrClientIsNil
+	}
+	return cli.Store.Sessions.HasSession(jid.SignalAddress().String())
+}
+
+// PurgeSession deletes the Signal session with the given device, so that a new one will be established
+// the next time a message is sent to or received from it.
+//
+// This can be used to recover from broken sessions that cause every message to fail decryption.
+// The JID must include the device part (e.g. the Sender of a message event).
+func (cli *Client) PurgeSession(jid types.JID) error {
+	if cli == nil {
+		return ErrClientIsNil
+	}
+	err := cli.Store.Sessions.DeleteSession(jid.SignalAddress().String())
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	cli.sessionRecreateHistoryLock.Lock()
+	delete(cli.sessionRecreateHistory, jid)
+	cli.sessionRecreateHistoryLock.Unlock()
+	cli.Log.Debugf("Purged Signal session with %s", jid)
+	return nil
+}
+
+// StoreHealth contains statistics about the local Signal store, returned by Client.CheckStoreHealth.
+type StoreHealth struct {
+	// The number of stored Signal sessions, or -1 if the session store doesn't support counting.
+	Sessions int
+	// The number of prekeys that have been uploaded to the server, but not used yet.
+	UploadedPreKeys int
+	// The number of sessions that were recreated in the last hour because of repeated decryption failures.
+	RecentlyRecreatedSessions int
+	// The number of incoming messages that failed to decrypt and were requested again with a retry receipt.
+	RetriedMessages int
+}
+
+type sessionCounter interface {
+	CountSessions() (int, error)
+}
+
+// CheckStoreHealth returns statistics about the local Signal store.
+//
+// A large number of recently recreated sessions usually means that some sessions are broken,
+// which can be fixed by calling PurgeSession for the affected devices.
+func (cli *Client) CheckStoreHealth() (*StoreHealth, error) {
+	if cli == nil {
+		return nil, ErrClientIsNil
+	}
+	var health StoreHealth
+	var err error
+	if counter, ok := cli.Store.Sessions.(sessionCounter); ok {
+		health.Sessions, err = counter.CountSessions()
+		if err != nil {
+			return nil, fmt.Errorf("failed to count sessions: %w", err)
+		}
+	} else {
+		health.Sessions = -1
+	}
+	health.UploadedPreKeys, err = cli.Store.PreKeys.UploadedPreKeyCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count uploaded prekeys: %w", err)
+	}
+	cli.sessionRecreateHistoryLock.Lock()
+	for _, recreatedAt := range cli.sessionRecreateHistory {
+		if time.Since(recreatedAt) < recreateSessionTimeout {
+			health.RecentlyRecreatedSessions++
+		}
+	}
+	cli.sessionRecreateHistoryLock.Unlock()
+	cli.messageRetriesLock.Lock()
+	health.RetriedMessages = len(cli.messageRetries)
+	cli.messageRetriesLock.Unlock()
+	return &health, nil
+}
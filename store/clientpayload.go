@@ -100,6 +100,10 @@ func SetWAVersion(version WAVersionContainer) {
 	waVersionHash = version.Hash()
 }
 
+// BaseClientPayload is the template for the client payload sent when connecting.
+//
+// It is shared by all clients in the process and must not be modified while any client is connecting.
+// Use Client.GetClientPayload to customize the payload for a single client.
 var BaseClientPayload = &waProto.ClientPayload{
 	UserAgent: &waProto.ClientPayload_UserAgent{
 		Platform:       waProto.ClientPayload_UserAgent_WEB.Enum(),
@@ -122,6 +126,7 @@ var BaseClientPayload = &waProto.ClientPayload{
 	ConnectReason: waProto.ClientPayload_USER_ACTIVATED.Enum(),
 }
 
+// DeviceProps is the device info sent to the phone when pairing. Like BaseClientPayload, it is shared by all clients.
 var DeviceProps = &waProto.DeviceProps{
 	Os: proto.String("whatsmeow"),
 	Version: &waProto.DeviceProps_AppVersion{
@@ -133,6 +138,9 @@ var DeviceProps = &waProto.DeviceProps{
 	RequireFullSync: proto.Bool(false),
 }
 
+// SetOSInfo updates the OS name and version in DeviceProps and BaseClientPayload.
+//
+// This affects all clients in the process, so it should be called before any clients are connected.
 func SetOSInfo(name string, version [3]uint32) {
 	DeviceProps.Os = &name
 	DeviceProps.Version.Primary = &version[0]
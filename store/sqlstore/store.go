@@ -73,6 +73,7 @@ const (
 	deleteAllIdentitiesQuery = `DELETE FROM whatsmeow_identity_keys WHERE our_jid=$1 AND their_id LIKE $2`
 	deleteIdentityQuery      = `DELETE FROM whatsmeow_identity_keys WHERE our_jid=$1 AND their_id=$2`
 	getIdentityQuery         = `SELECT identity FROM whatsmeow_identity_keys WHERE our_jid=$1 AND their_id=$2`
+	getAllIdentitiesQuery    = `SELECT their_id, identity FROM whatsmeow_identity_keys WHERE our_jid=$1`
 )
 
 func (s *SQLStore) PutIdentity(address string, key [32]byte) error {
@@ -90,6 +91,42 @@ func (s *SQLStore) DeleteIdentity(address string) error {
 	return err
 }
 
+// GetAllIdentities returns all stored identity keys, keyed by Signal address.
+func (s *SQLStore) GetAllIdentities() (map[string][32]byte, error) {
+	rows, err := s.db.Query(getAllIdentitiesQuery, s.JID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	identities := make(map[string][32]byte)
+	for rows.Next() {
+		var address string
+		var identity []byte
+		err = rows.Scan(&address, &identity)
+		if err != nil {
+			return nil, err
+		} else if len(identity) != 32 {
+			return nil, ErrInvalidLength
+		}
+		identities[address] = *(*[32]byte)(identity)
+	}
+	return identities, rows.Err()
+}
+
+// GetIdentity returns the stored identity key of the given Signal address, or nil if there's no stored identity.
+func (s *SQLStore) GetIdentity(address string) ([]byte, error) {
+	var identity []byte
+	err := s.db.QueryRow(getIdentityQuery, s.JID, address).Scan(&identity)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if len(identity) != 32 {
+		return nil, ErrInvalidLength
+	}
+	return identity, nil
+}
+
 func (s *SQLStore) IsTrustedIdentity(address string, key [32]byte) (bool, error) {
 	var existingIdentity []byte
 	err := s.db.QueryRow(getIdentityQuery, s.JID, address).Scan(&existingIdentity)
@@ -113,6 +150,8 @@ const (
 	`
 	deleteAllSessionsQuery = `DELETE FROM whatsmeow_sessions WHERE our_jid=$1 AND their_id LIKE $2`
 	deleteSessionQuery     = `DELETE FROM whatsmeow_sessions WHERE our_jid=$1 AND their_id=$2`
+	countSessionsQuery     = `SELECT COUNT(*) FROM whatsmeow_sessions WHERE our_jid=$1`
+	getAllSessionsQuery    = `SELECT their_id, session FROM whatsmeow_sessions WHERE our_jid=$1`
 )
 
 func (s *SQLStore) GetSession(address string) (session []byte, err error) {
@@ -146,6 +185,32 @@ func (s *SQLStore) DeleteSession(address string) error {
 	return err
 }
 
+// GetAllSessions returns all stored Signal sessions, keyed by Signal address.
+func (s *SQLStore) GetAllSessions() (map[string][]byte, error) {
+	rows, err := s.db.Query(getAllSessionsQuery, s.JID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := make(map[string][]byte)
+	for rows.Next() {
+		var address string
+		var session []byte
+		err = rows.Scan(&address, &session)
+		if err != nil {
+			return nil, err
+		}
+		sessions[address] = session
+	}
+	return sessions, rows.Err()
+}
+
+// CountSessions returns the number of Signal sessions stored for this device.
+func (s *SQLStore) CountSessions() (count int, err error) {
+	err = s.db.QueryRow(countSessionsQuery, s.JID).Scan(&count)
+	return
+}
+
 const (
 	getLastPreKeyIDQuery        = `SELECT MAX(key_id) FROM whatsmeow_pre_keys WHERE jid=$1`
 	insertPreKeyQuery           = `INSERT INTO whatsmeow_pre_keys (jid, key_id, key, uploaded) VALUES ($1, $2, $3, $4)`
@@ -154,6 +219,7 @@ const (
 	deletePreKeyQuery           = `DELETE FROM whatsmeow_pre_keys WHERE jid=$1 AND key_id=$2`
 	markPreKeysAsUploadedQuery  = `UPDATE whatsmeow_pre_keys SET uploaded=true WHERE jid=$1 AND key_id<=$2`
 	getUploadedPreKeyCountQuery = `SELECT COUNT(*) FROM whatsmeow_pre_keys WHERE jid=$1 AND uploaded=true`
+	getUploadedPreKeysQuery     = `SELECT key_id, key FROM whatsmeow_pre_keys WHERE jid=$1 AND uploaded=true ORDER BY key_id`
 )
 
 func (s *SQLStore) genOnePreKey(id uint32, markUploaded bool) (*keys.PreKey, error) {
@@ -237,6 +303,30 @@ func scanPreKey(row scannable) (*keys.PreKey, error) {
 	}, nil
 }
 
+// GetAllUploadedPreKeys returns all prekeys that have been uploaded to the server and not used yet.
+func (s *SQLStore) GetAllUploadedPreKeys() ([]*keys.PreKey, error) {
+	rows, err := s.db.Query(getUploadedPreKeysQuery, s.JID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var preKeys []*keys.PreKey
+	for rows.Next() {
+		key, err := scanPreKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		preKeys = append(preKeys, key)
+	}
+	return preKeys, rows.Err()
+}
+
+// PutUploadedPreKey stores the given prekey and marks it as already uploaded to the server.
+func (s *SQLStore) PutUploadedPreKey(key *keys.PreKey) error {
+	_, err := s.db.Exec(insertPreKeyQuery, s.JID, key.KeyID, key.Priv[:], true)
+	return err
+}
+
 func (s *SQLStore) GetPreKey(id uint32) (*keys.PreKey, error) {
 	return scanPreKey(s.db.QueryRow(getPreKeyQuery, s.JID, id))
 }
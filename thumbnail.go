@@ -0,0 +1,70 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+)
+
+// ThumbnailSize is the maximum width and height of thumbnails generated by GenerateThumbnail.
+const ThumbnailSize = 100
+
+// GenerateThumbnail scales the given image so that it fits in ThumbnailSize×ThumbnailSize
+// and encodes it as a JPEG, which can be used as the JPEGThumbnail of image, video and document messages.
+//
+// To make a thumbnail for a video, decode a frame of the video with your preferred library and pass it here.
+func GenerateThumbnail(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("image is empty")
+	}
+	thumbWidth, thumbHeight := width, height
+	if width > ThumbnailSize || height > ThumbnailSize {
+		if width > height {
+			thumbWidth, thumbHeight = ThumbnailSize, max(height*ThumbnailSize/width, 1)
+		} else {
+			thumbWidth, thumbHeight = max(width*ThumbnailSize/height, 1), ThumbnailSize
+		}
+	}
+	thumb := image.NewRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+	for y := 0; y < thumbHeight; y++ {
+		srcY0, srcY1 := bounds.Min.Y+y*height/thumbHeight, bounds.Min.Y+max((y+1)*height/thumbHeight, y*height/thumbHeight+1)
+		for x := 0; x < thumbWidth; x++ {
+			srcX0, srcX1 := bounds.Min.X+x*width/thumbWidth, bounds.Min.X+max((x+1)*width/thumbWidth, x*width/thumbWidth+1)
+			thumb.SetRGBA(x, y, averageColor(img, srcX0, srcY0, srcX1, srcY1))
+		}
+	}
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 75})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// averageColor returns the average color of the given rectangle of the image.
+func averageColor(img image.Image, x0, y0, x1, y1 int) color.RGBA {
+	var r, g, b, a, count uint64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			pr, pg, pb, pa := img.At(x, y).RGBA()
+			r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+			count++
+		}
+	}
+	return color.RGBA{
+		R: uint8(r / count >> 8),
+		G: uint8(g / count >> 8),
+		B: uint8(b / count >> 8),
+		A: uint8(a / count >> 8),
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package whatsmeow
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestGenerateThumbnail(t *testing.T) {
+	testCases := []struct {
+		name                          string
+		bounds                        image.Rectangle
+		expectedWidth, expectedHeight int
+	}{
+		{"landscape", image.Rect(0, 0, 400, 200), 100, 50},
+		{"portrait", image.Rect(0, 0, 300, 600), 50, 100},
+		{"square", image.Rect(0, 0, 250, 250), 100, 100},
+		{"small image isn't upscaled", image.Rect(0, 0, 60, 40), 60, 40},
+		{"very wide", image.Rect(0, 0, 1000, 5), 100, 1},
+		{"non-zero origin", image.Rect(50, 50, 450, 250), 100, 50},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img := image.NewRGBA(tc.bounds)
+			for y := tc.bounds.Min.Y; y < tc.bounds.Max.Y; y++ {
+				for x := tc.bounds.Min.X; x < tc.bounds.Max.X; x++ {
+					img.SetRGBA(x, y, color.RGBA{R: 255, A: 255})
+				}
+			}
+			data, err := GenerateThumbnail(img)
+			if err != nil {
+				t.Fatalf("failed to generate thumbnail: %v", err)
+			}
+			thumb, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("thumbnail isn't a valid JPEG: %v", err)
+			}
+			if thumb.Bounds().Dx() != tc.expectedWidth || thumb.Bounds().Dy() != tc.expectedHeight {
+				t.Errorf("expected %dx%d thumbnail, got %dx%d", tc.expectedWidth, tc.expectedHeight, thumb.Bounds().Dx(), thumb.Bounds().Dy())
+			}
+			r, g, b, _ := thumb.At(0, 0).RGBA()
+			if r>>8 < 200 || g>>8 > 50 || b>>8 > 50 {
+				t.Errorf("expected red thumbnail, got %d/%d/%d", r>>8, g>>8, b>>8)
+			}
+		})
+	}
+}
+
+func TestGenerateThumbnailEmpty(t *testing.T) {
+	for _, bounds := range []image.Rectangle{image.Rect(0, 0, 0, 0), image.Rect(0, 0, 100, 0), image.Rect(10, 10, 10, 20)} {
+		_, err := GenerateThumbnail(image.NewRGBA(bounds))
+		if err == nil {
+			t.Errorf("expected error for empty image with bounds %v", bounds)
+		}
+	}
+}
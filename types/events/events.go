@@ -12,6 +12,8 @@ import (
 	"strconv"
 	"time"
 
+	"google.golang.org/protobuf/proto"
+
 	waBinary "go.mau.fi/whatsmeow/binary"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	armadillo "go.mau.fi/whatsmeow/proto"
@@ -154,6 +156,35 @@ func (tb *TemporaryBan) String() string {
 	return fmt.Sprintf("You've been temporarily banned: %v. The ban expires in %v", tb.Code, tb.Expire)
 }
 
+// Banned is emitted when there's a connection failure with the ConnectFailureBanned reason code.
+//
+// The session data will be deleted from the device store after this event. A LoggedOut event with the same
+// reason is always dispatched right after this one, so existing logout handlers keep working. Only LoggedOut
+// implements PermanentDisconnect, so a ban produces a single permanent disconnect event.
+type Banned struct {
+	Reason ConnectFailureReason
+	// The expiry of the ban, if the server provided one. Zero means no expiry was included.
+	Expire time.Duration
+
+	// Human-readable explanation of the ban, if the server provided one.
+	Header  string
+	Subtext string
+}
+
+func (b *Banned) String() string {
+	msg := "Your account has been banned"
+	if b.Header != "" {
+		msg = fmt.Sprintf("%s: %s", msg, b.Header)
+	}
+	if b.Subtext != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, b.Subtext)
+	}
+	if b.Expire != 0 {
+		msg = fmt.Sprintf("%s. The ban expires in %v", msg, b.Expire)
+	}
+	return msg
+}
+
 // ConnectFailureReason is an error code included in connection failure events.
 type ConnectFailureReason int
 
@@ -162,7 +193,10 @@ const (
 	ConnectFailureLoggedOut      ConnectFailureReason = 401
 	ConnectFailureTempBanned     ConnectFailureReason = 402
 	ConnectFailureMainDeviceGone ConnectFailureReason = 403 // this is now called LOCKED in the whatsapp web code
-	ConnectFailureUnknownLogout  ConnectFailureReason = 406 // this is now called BANNED in the whatsapp web code
+	ConnectFailureBanned         ConnectFailureReason = 406 // this was previously called ConnectFailureUnknownLogout
+
+	// Deprecated: use ConnectFailureBanned
+	ConnectFailureUnknownLogout = ConnectFailureBanned
 
 	ConnectFailureClientOutdated ConnectFailureReason = 405
 	ConnectFailureBadUserAgent   ConnectFailureReason = 409
@@ -180,7 +214,7 @@ var connectFailureReasonMessage = map[ConnectFailureReason]string{
 	ConnectFailureLoggedOut:      "logged out from another device",
 	ConnectFailureTempBanned:     "account temporarily banned",
 	ConnectFailureMainDeviceGone: "primary device was logged out", // seems to happen for both bans and switching phones
-	ConnectFailureUnknownLogout:  "logged out for unknown reason",
+	ConnectFailureBanned:         "logged out for unknown reason",
 	ConnectFailureClientOutdated: "client is out of date",
 	ConnectFailureBadUserAgent:   "client user agent was rejected",
 	ConnectFailureCATExpired:     "messenger crypto auth token has expired",
@@ -189,7 +223,7 @@ var connectFailureReasonMessage = map[ConnectFailureReason]string{
 
 // IsLoggedOut returns true if the client should delete session data due to this connect failure.
 func (cfr ConnectFailureReason) IsLoggedOut() bool {
-	return cfr == ConnectFailureLoggedOut || cfr == ConnectFailureMainDeviceGone || cfr == ConnectFailureUnknownLogout
+	return cfr == ConnectFailureLoggedOut || cfr == ConnectFailureMainDeviceGone || cfr == ConnectFailureBanned
 }
 
 func (cfr ConnectFailureReason) NumberString() string {
@@ -258,6 +292,23 @@ type UndecryptableMessage struct {
 	IsUnavailable bool
 
 	DecryptFailMode DecryptFailMode
+
+	// The error that caused decryption to fail. Errors from libsignal (like signalerror.ErrBadMAC or
+	// signalerror.ErrNoSessionForUser) and padding errors (whatsmeow.ErrInvalidPadding) are wrapped,
+	// so they can be checked with errors.Is.
+	Error error
+}
+
+// DecryptionFailure is emitted when an incoming message still can't be decrypted after sending the maximum number
+// of retry receipts (Client.MaxRetryReceipts). The message is most likely lost at that point.
+//
+// An UndecryptableMessage event is emitted for every failed attempt before this.
+type DecryptionFailure struct {
+	Info types.MessageInfo
+	// The type of the last ciphertext that failed to decrypt (msg, pkmsg or skmsg).
+	EncType string
+	// The number of retry receipts that were sent for the message.
+	RetryCount int
 }
 
 type NewsletterMessageMeta struct {
@@ -280,6 +331,10 @@ type Message struct {
 	IsDocumentWithCaption bool // True if the message was unwrapped from a DocumentWithCaptionMessage
 	IsLottieSticker       bool // True if the message was unwrapped from a LottieStickerMessage
 	IsEdit                bool // True if the message was unwrapped from an EditedMessage
+	IsBotInvoke           bool // True if the message was unwrapped from a BotInvokeMessage
+
+	// Metadata about Meta AI or other bots. This is set on messages generated by bots as well as messages invoking bots.
+	BotMetadata *waProto.BotMetadata
 
 	// If this event was parsed from a WebMessageInfo (i.e. from a history sync or unavailable message request), the source data is here.
 	SourceWebMsg *waProto.WebMessageInfo
@@ -293,6 +348,14 @@ type Message struct {
 	// The raw message struct. This is the raw unmodified data, which means the actual message might
 	// be wrapped in DeviceSentMessage, EphemeralMessage or ViewOnceMessage.
 	RawMessage *waProto.Message
+	// The downloaded attachment of the message, if its media type was enabled with Client.AutoDownloadMedia.
+	AutoDownloadedMedia []byte
+	// The decrypted protobuf bytes that RawMessage was parsed from (with padding removed).
+	// This is only set if Client.IncludeRawMessageBytes is true.
+	RawMessageBytes []byte
+	// The node that the message was parsed from. For encrypted messages, the enc children contain the ciphertext.
+	// This is only set if Client.IncludeSourceNode is true.
+	SourceNode *waBinary.Node
 }
 
 type FBMessage struct {
@@ -320,7 +383,7 @@ func (evt *FBMessage) GetArmadillo() *waArmadilloApplication.Armadillo {
 	return nil
 }
 
-// UnwrapRaw fills the Message, IsEphemeral and IsViewOnce fields based on the raw message in the RawMessage field.
+// UnwrapRaw fills the Message, BotMetadata and Is* fields based on the raw message in the RawMessage field.
 func (evt *Message) UnwrapRaw() *Message {
 	evt.Message = evt.RawMessage
 	if evt.Message.GetDeviceSentMessage().GetMessage() != nil {
@@ -361,9 +424,44 @@ func (evt *Message) UnwrapRaw() *Message {
 		evt.Message = evt.Message.GetEditedMessage().GetMessage()
 		evt.IsEdit = true
 	}
+	if evt.Message.GetBotInvokeMessage().GetMessage() != nil {
+		evt.Message = evt.Message.GetBotInvokeMessage().GetMessage()
+		evt.IsBotInvoke = true
+	}
+	evt.BotMetadata = evt.Message.GetMessageContextInfo().GetBotMetadata()
+	if evt.BotMetadata == nil {
+		evt.BotMetadata = evt.RawMessage.GetMessageContextInfo().GetBotMetadata()
+	}
 	return evt
 }
 
+// GetQuotedMessageKey returns the key of the quoted message if the message is a reply, or nil otherwise.
+// The quoted message itself is in the QuotedMessage field of GetContextInfo().
+// FromMe is not set: compare Participant with your own JID instead.
+func (evt *Message) GetQuotedMessageKey() *waProto.MessageKey {
+	ctxInfo := evt.GetContextInfo()
+	if ctxInfo.GetStanzaID() == "" {
+		return nil
+	}
+	key := &waProto.MessageKey{
+		RemoteJID:   proto.String(evt.Info.Chat.String()),
+		ID:          ctxInfo.StanzaID,
+		Participant: ctxInfo.Participant,
+	}
+	if ctxInfo.GetRemoteJID() != "" {
+		key.RemoteJID = ctxInfo.RemoteJID
+	}
+	return key
+}
+
+// GetContextInfo returns the ContextInfo of the message content, which contains things like the quoted
+// message in replies and mentioned users. Returns nil if the message type doesn't have a ContextInfo.
+//
+// This walks the message fields with reflection, so it's computed on each call rather than in UnwrapRaw.
+func (evt *Message) GetContextInfo() *waProto.ContextInfo {
+	return evt.Message.FindContextInfo(false)
+}
+
 // Deprecated: use types.ReceiptType directly
 type ReceiptType = types.ReceiptType
 
@@ -478,6 +576,13 @@ type UserAbout struct {
 	Timestamp time.Time // The timestamp when the status was changed.
 }
 
+// SessionEstablished is emitted when a new Signal session is established with another device
+// by decrypting a prekey message from them.
+type SessionEstablished struct {
+	JID       types.JID
+	Timestamp time.Time
+}
+
 // IdentityChange is emitted when another user changes their primary device.
 type IdentityChange struct {
 	JID       types.JID
@@ -486,6 +591,15 @@ type IdentityChange struct {
 	// Implicit will be set to true if the event was triggered by an untrusted identity error,
 	// rather than an identity change notification from the server.
 	Implicit bool
+	// For implicit changes, the new identity key of the device that sent the message.
+	NewIdentityKey []byte
+	// For implicit changes, the previously stored identity key of the device. This is nil if the identity store
+	// doesn't support reading identities (sqlstore does) or if reading it failed.
+	OldIdentityKey []byte
+	// For implicit changes, true if the new identity was trusted automatically (see Client.AutoTrustIdentity).
+	// If false, messages from the device will fail to decrypt until Client.TrustIdentity is called.
+	// Untrusted changes are emitted again for every message that fails to decrypt.
+	Trusted bool
 }
 
 // PrivacySettings is emitted when the user changes their privacy settings.
@@ -578,6 +692,9 @@ type NewsletterMuteChange struct {
 	Mute types.NewsletterMuteState `json:"mute"`
 }
 
+// NewsletterLiveUpdate is emitted when the view or reaction counts of messages in a channel change.
+//
+// Live updates are only sent for channels that have been subscribed to with Client.NewsletterSubscribeLiveUpdates.
 type NewsletterLiveUpdate struct {
 	JID      types.JID
 	Time     time.Time
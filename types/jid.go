@@ -88,6 +88,13 @@ func (jid JID) ToNonAD() JID {
 	}
 }
 
+// IsSameUser returns true if both JIDs belong to the same user, ignoring the agent and device parts.
+//
+// Note that a user's phone number JID and hidden (LID) JID are not considered to be the same user.
+func (jid JID) IsSameUser(other JID) bool {
+	return jid.User == other.User && jid.Server == other.Server
+}
+
 // SignalAddress returns the Signal protocol address for the user.
 func (jid JID) SignalAddress() *signalProtocol.SignalAddress {
 	user := jid.User
@@ -0,0 +1,101 @@
+// Copyright (c) 2024 Tulir Asokan
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package types_test
+
+import (
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestJIDIsSameUser(t *testing.T) {
+	user := types.NewJID("1111", types.DefaultUserServer)
+	testCases := []struct {
+		name     string
+		jid      types.JID
+		other    types.JID
+		expected bool
+	}{
+		{"identical", user, user, true},
+		{"different device", types.NewADJID("1111", 0, 3), types.NewADJID("1111", 0, 12), true},
+		{"device and non-AD", types.NewADJID("1111", 0, 3), user, true},
+		{"integrator", types.JID{User: "1111", Server: types.DefaultUserServer, Integrator: 5}, user, true},
+		{"different user", types.NewADJID("2222", 0, 3), user, false},
+		{"hidden user", types.NewADJID("1111", 1, 3), user, false},
+		{"group with same user part", types.NewJID("1111", types.GroupServer), user, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.jid.IsSameUser(tc.other) != tc.expected || tc.other.IsSameUser(tc.jid) != tc.expected {
+				t.Errorf("expected IsSameUser(%s, %s) to be %t", tc.jid, tc.other, tc.expected)
+			}
+		})
+	}
+}
+
+func TestJIDToNonAD(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected types.JID
+	}{
+		{"1111@s.whatsapp.net", types.NewJID("1111", types.DefaultUserServer)},
+		{"1111:5@s.whatsapp.net", types.NewJID("1111", types.DefaultUserServer)},
+		{"1111.0:5@s.whatsapp.net", types.NewJID("1111", types.DefaultUserServer)},
+		{"1111:2@lid", types.NewJID("1111", types.HiddenUserServer)},
+		{"123456-789@g.us", types.NewJID("123456-789", types.GroupServer)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			jid, err := types.ParseJID(tc.input)
+			if err != nil {
+				t.Fatalf("failed to parse JID: %v", err)
+			} else if nonAD := jid.ToNonAD(); nonAD != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, nonAD)
+			}
+		})
+	}
+}
+
+func TestParseJIDRoundTrip(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected types.JID
+	}{
+		{"1111@s.whatsapp.net", types.NewJID("1111", types.DefaultUserServer)},
+		{"1111:5@s.whatsapp.net", types.NewADJID("1111", 0, 5)},
+		{"1111.1:5@s.whatsapp.net", types.JID{User: "1111", RawAgent: 1, Device: 5, Server: types.DefaultUserServer}},
+		{"1111:2@lid", types.JID{User: "1111", Device: 2, Server: types.HiddenUserServer}},
+		{"123456-789@g.us", types.NewJID("123456-789", types.GroupServer)},
+		{"120363000000000000@g.us", types.NewJID("120363000000000000", types.GroupServer)},
+		{"status@broadcast", types.StatusBroadcastJID},
+		{"1234567890@broadcast", types.NewJID("1234567890", types.BroadcastServer)},
+		{"120363000000000000@newsletter", types.NewJID("120363000000000000", types.NewsletterServer)},
+		{"s.whatsapp.net", types.ServerJID},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			jid, err := types.ParseJID(tc.input)
+			if err != nil {
+				t.Fatalf("failed to parse JID: %v", err)
+			} else if jid != tc.expected {
+				t.Fatalf("expected %#v, got %#v", tc.expected, jid)
+			} else if str := jid.String(); str != tc.input {
+				t.Errorf("expected String() to return %s, got %s", tc.input, str)
+			}
+		})
+	}
+}
+
+func TestParseJIDInvalid(t *testing.T) {
+	for _, input := range []string{"1111.1.2:5@s.whatsapp.net", "1111:5:6@s.whatsapp.net", "1111:abc@s.whatsapp.net", "1111.x:5@s.whatsapp.net"} {
+		t.Run(input, func(t *testing.T) {
+			if _, err := types.ParseJID(input); err == nil {
+				t.Errorf("expected error parsing %s", input)
+			}
+		})
+	}
+}
@@ -81,6 +81,9 @@ type MessageInfo struct {
 	MediaType string
 	Edit      EditAttribute
 
+	IsOffline    bool // True if the message was queued on the server while the client was offline.
+	OfflineIndex int  // The value of the offline attribute of queued messages.
+
 	MsgBotInfo  MsgBotInfo
 	MsgMetaInfo MsgMetaInfo
 
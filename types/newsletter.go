@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"go.mau.fi/util/jsontime"
 
@@ -148,6 +149,9 @@ type NewsletterText struct {
 
 type NewsletterMessage struct {
 	MessageServerID MessageServerID
+	MessageID       MessageID
+	Type            string
+	Timestamp       time.Time
 	ViewsCount      int
 	ReactionCounts  map[string]int
 
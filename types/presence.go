@@ -72,6 +72,8 @@ func (rt ReceiptType) GoString() string {
 		return "types.ReceiptTypeDelivered"
 	case ReceiptTypePlayed:
 		return "types.ReceiptTypePlayed"
+	case ReceiptTypePlayedSelf:
+		return "types.ReceiptTypePlayedSelf"
 	default:
 		return fmt.Sprintf("types.ReceiptType(%#v)", string(rt))
 	}
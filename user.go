@@ -7,13 +7,16 @@
 package whatsmeow
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"image"
 	"strings"
 
 	"google.golang.org/protobuf/proto"
 
+	"go.mau.fi/whatsmeow/appstate"
 	waBinary "go.mau.fi/whatsmeow/binary"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/types"
@@ -157,6 +160,34 @@ func (cli *Client) SetStatusMessage(msg string) error {
 	return err
 }
 
+// SetProfilePicture updates the current user's profile picture. Returns the new picture ID.
+//
+// The avatar must be a square JPEG image, ErrInvalidImageFormat is returned for other images without sending
+// anything to the server. WhatsApp clients use 640x640 pictures. The bytes can be nil to remove the picture.
+func (cli *Client) SetProfilePicture(avatar []byte) (string, error) {
+	if avatar != nil {
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(avatar))
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidImageFormat, err)
+		} else if format != "jpeg" {
+			return "", fmt.Errorf("%w: expected jpeg, got %s", ErrInvalidImageFormat, format)
+		} else if cfg.Width != cfg.Height {
+			return "", fmt.Errorf("%w: image must be square, got %dx%d", ErrInvalidImageFormat, cfg.Width, cfg.Height)
+		}
+	}
+	return cli.setPicture(types.EmptyJID, avatar)
+}
+
+// SetPushName updates the current user's display name (push name), which is shown to users who don't have you saved as a contact.
+//
+// The name is synced to other devices through app state, and the value in Client.Store is updated when the patch is applied.
+func (cli *Client) SetPushName(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("push name can't be empty")
+	}
+	return cli.SendAppState(appstate.BuildSettingPushName(name))
+}
+
 // IsOnWhatsApp checks if the given phone numbers are registered on WhatsApp.
 // The phone numbers should be in international format, including the `+` prefix.
 func (cli *Client) IsOnWhatsApp(phones []string) ([]types.IsOnWhatsAppResponse, error) {
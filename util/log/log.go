@@ -22,6 +22,24 @@ type Logger interface {
 	Sub(module string) Logger
 }
 
+// FieldLogger is an optional extension of Logger for loggers that support structured key/value fields.
+type FieldLogger interface {
+	Logger
+	WithFields(fields map[string]any) Logger
+}
+
+// WithFields returns a logger with the given fields attached to all log lines.
+//
+// If the logger doesn't implement FieldLogger, it's returned as-is.
+// The text loggers don't support fields, so log messages should still be readable without them.
+func WithFields(log Logger, fields map[string]any) Logger {
+	fieldLog, ok := log.(FieldLogger)
+	if !ok {
+		return log
+	}
+	return fieldLog.WithFields(fields)
+}
+
 type noopLogger struct{}
 
 func (n *noopLogger) Errorf(_ string, _ ...interface{}) {}
@@ -36,3 +36,7 @@ func (z *zeroLogger) Sub(module string) Logger {
 }
 
 var _ Logger = &zeroLogger{}
+
+func (z *zeroLogger) WithFields(fields map[string]any) Logger {
+	return &zeroLogger{mod: z.mod, Logger: z.Logger.With().Fields(fields).Logger()}
+}